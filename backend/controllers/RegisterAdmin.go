@@ -2,34 +2,13 @@ package controllers
 
 import (
 	"admin-dashboard/database" // Replace with your actual package for database connection
+	"admin-dashboard/models"
+	"admin-dashboard/password"
 	"github.com/joho/godotenv"
-    "regexp"
-	"errors"
-	"golang.org/x/crypto/bcrypt"
 	"os"
 	"log"
 )
 
-func validatePassword(password string) error {
-    // Check minimum length
-    if len(password) < 8 {
-        return errors.New("password must be at least 8 characters long")
-    }
-
-    // Check for at least one number
-    if match, _ := regexp.MatchString(`[0-9]`, password); !match {
-        return errors.New("password must contain at least one number")
-    }
-
-    // Check for at least one special character
-    if match, _ := regexp.MatchString(`[!@#$%^&*]`, password); !match {
-        return errors.New("password must contain at least one special character (!@#$%^&*)")
-    }
-
-    return nil
-}
-
-
 func RegisterAdmin() {
 	// Load environment variables
 	err := godotenv.Load()
@@ -47,11 +26,6 @@ func RegisterAdmin() {
 		return
 	}
 
-	// Validate password
-    if err := validatePassword(adminPassword); err != nil {
-        log.Fatalf("Error validating admin password: %s", err)
-    }
-
 	// Check if the admin already exists
 	var exists bool
 	err = database.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM credentials WHERE email = $1)", adminEmail).Scan(&exists)
@@ -61,19 +35,32 @@ func RegisterAdmin() {
 	}
 
 	if exists {
+		// Make sure the configured admin keeps the admin role
+		if err := models.SetRoleByEmail(adminEmail, models.RoleAdmin); err != nil {
+			log.Println("Error assigning admin role:", err)
+			return
+		}
 		log.Println("Admin already exists. Skipping registration.")
 		return
 	}
 
+	// Check the password against the policy only when creating the admin; there
+	// is no history yet, and later boots would find their own hash "reused"
+	if err := password.DefaultPolicy.Validate(adminPassword, adminEmail, nil); err != nil {
+		log.Fatalf("Error validating admin password: %s", err)
+	}
+
 	// Hash the admin password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(adminPassword), bcrypt.DefaultCost)
+	hashedPassword, err := password.Hash(adminPassword)
 	if err != nil {
 		log.Println("Error hashing admin password:", err)
 		return
 	}
 
 	// Insert admin into the database
-	_, err = database.DB.Exec("INSERT INTO credentials (email, password_hash) VALUES ($1, $2)", adminEmail, string(hashedPassword))
+	_, err = database.DB.Exec(`
+		INSERT INTO credentials (email, password_hash, role_id, user_id)
+		VALUES ($1, $2, (SELECT id FROM roles WHERE name = $3), (SELECT id FROM users WHERE email = $1))`, adminEmail, hashedPassword, models.RoleAdmin)
 	if err != nil {
 		log.Println("Error registering admin:", err)
 		return
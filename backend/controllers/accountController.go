@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Block the login account of an employee and sign it out everywhere
+func DeactivateUser(c *gin.Context) {
+	setUserActive(c, false)
+}
+
+// Allow the login account of an employee to sign in again
+func ActivateUser(c *gin.Context) {
+	setUserActive(c, true)
+}
+
+func setUserActive(c *gin.Context, active bool) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam) // Convert to int
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	email, err := models.GetUserEmailByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	if !active && email == c.GetString("email") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot deactivate your own account"})
+		return
+	}
+
+	// Only callers that outrank the account (or manage roles) may lock it out
+	role, err := models.GetAccountRoleByUserID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no login account"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch account"})
+		return
+	}
+	if !auditActor(c).MayManageAccount(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to change this login account"})
+		return
+	}
+
+	_, err = models.SetAccountActive(id, active)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no login account"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update account"})
+		return
+	}
+
+	if active {
+		c.JSON(http.StatusOK, gin.H{"message": "Account activated successfully"})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"message": "Account deactivated successfully"})
+	}
+}
@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Longest lifetime that can be requested for an API token, in days
+const maxAPITokenDays = 3650
+
+// Service account names: lowercase letters, digits, "-" and "_" (no "@", so
+// they never look like an email in created_by columns)
+var serviceAccountNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{2,29}$`)
+
+// Request body for creating a personal or service account token
+type APITokenInput struct {
+	Name          string   `json:"name" binding:"required,max=50"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays *int     `json:"expires_in_days"` // omit for a token that never expires
+}
+
+// Function to generate a new API token. Returns the token (shown once), its
+// hash for storage and a short prefix to recognise it by in listings.
+func generateAPIToken() (string, string, string, error) {
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	token := models.APITokenPrefix + secret
+	return token, utils.HashToken(token), token[:len(models.APITokenPrefix)+6], nil
+}
+
+// Function to check the requested scopes against the ones the owner may hold.
+// Returns the scopes without duplicates.
+func validateScopes(requested, allowed []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+
+	allowedSet := map[string]bool{}
+	for _, scope := range allowed {
+		allowedSet[scope] = true
+	}
+	seen := map[string]bool{}
+	scopes := []string{}
+	for _, scope := range requested {
+		if !allowedSet[scope] {
+			return nil, fmt.Errorf("scope %q is not available", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// Function to turn the requested lifetime into an expiry time (nil for no expiry)
+func apiTokenExpiry(days *int) (*time.Time, error) {
+	if days == nil {
+		return nil, nil
+	}
+	if *days < 1 || *days > maxAPITokenDays {
+		return nil, fmt.Errorf("expires_in_days must be between 1 and %d", maxAPITokenDays)
+	}
+	expiresAt := time.Now().AddDate(0, 0, *days)
+	return &expiresAt, nil
+}
+
+// List the API tokens of the signed-in account
+func GetPersonalAPITokens(c *gin.Context) {
+	tokens, err := models.GetPersonalAPITokens(c.GetString("email"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// Create an API token for the signed-in account. Scopes are limited to the
+// permissions of the account's role, and the token is only shown in this response.
+func CreatePersonalAPIToken(c *gin.Context) {
+	var input APITokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	allowed, err := models.GetRolePermissions(c.GetString("role"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking permissions"})
+		return
+	}
+	scopes, err := validateScopes(input.Scopes, allowed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expiresAt, err := apiTokenExpiry(input.ExpiresInDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, hash, prefix, err := generateAPIToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+	email := c.GetString("email")
+	id, err := models.CreatePersonalAPIToken(email, input.Name, hash, prefix, scopes, email, expiresAt)
+	if err != nil {
+		log.Printf("Error creating API token for %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         id,
+		"token":      token,
+		"scopes":     scopes,
+		"expires_at": expiresAt,
+	})
+}
+
+// Revoke one of the signed-in account's API tokens
+func RevokePersonalAPIToken(c *gin.Context) {
+	tokenID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	err = models.RevokePersonalAPIToken(c.GetString("email"), tokenID)
+	if err != nil {
+		if err.Error() == "token not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked successfully"})
+}
+
+// List every service account (admin only)
+func GetServiceAccounts(c *gin.Context) {
+	accounts, err := models.GetServiceAccounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch service accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"service_accounts": accounts})
+}
+
+// Create a named service account for scripts and integrations (admin only)
+func CreateServiceAccount(c *gin.Context) {
+	var input struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description" binding:"max=100"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if !serviceAccountNamePattern.MatchString(input.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be 3-30 lowercase letters, digits, '-' or '_'"})
+		return
+	}
+
+	id, err := models.CreateServiceAccount(input.Name, input.Description, callerName(c))
+	if err != nil {
+		if err.Error() == "service account already exists" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A service account with this name already exists"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": id})
+}
+
+// Disable a service account and revoke all of its tokens (admin only)
+func DisableServiceAccount(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+		return
+	}
+
+	if err := models.DisableServiceAccount(id); err != nil {
+		if err.Error() == "service account not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable service account"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Service account disabled successfully"})
+}
+
+// List the tokens of a service account (admin only)
+func GetServiceAccountTokens(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+		return
+	}
+
+	tokens, err := models.GetServiceAccountTokens(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// Create a token for a service account (admin only). Any known permission may
+// be granted as a scope; the token is only shown in this response.
+func CreateServiceAccountToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+		return
+	}
+
+	var input APITokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	allowed, err := models.GetPermissionNames()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking permissions"})
+		return
+	}
+	scopes, err := validateScopes(input.Scopes, allowed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expiresAt, err := apiTokenExpiry(input.ExpiresInDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, hash, prefix, err := generateAPIToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+	tokenID, err := models.CreateServiceAccountToken(id, input.Name, hash, prefix, scopes, callerName(c), expiresAt)
+	if err != nil {
+		if err.Error() == "service account not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		} else {
+			log.Printf("Error creating token for service account %d: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         tokenID,
+		"token":      token,
+		"scopes":     scopes,
+		"expires_at": expiresAt,
+	})
+}
+
+// Revoke a token of a service account (admin only)
+func RevokeServiceAccountToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+		return
+	}
+	tokenID, err := strconv.Atoi(c.Param("tokenId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	if err := models.RevokeServiceAccountToken(id, tokenID); err != nil {
+		if err.Error() == "token not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked successfully"})
+}
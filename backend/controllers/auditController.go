@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function to get who is making the current request, as recorded with the
+// changes it makes, and the role that limits which login accounts it may change
+func auditActor(c *gin.Context) models.AuditActor {
+	return models.AuditActor{
+		Actor:        callerName(c),
+		Impersonator: c.GetString("impersonator"),
+		RequestID:    c.GetString("request_id"),
+		IPAddress:    c.ClientIP(),
+		Role:         c.GetString("role"),
+		ManagesRoles: callerHasPermission(c, models.PermRolesManage),
+	}
+}
+
+// Function to leave salary changes out of audit events for callers without
+// users:salary:read. The events themselves are kept so the record's history stays complete.
+func auditEventsForCaller(c *gin.Context, events []models.AuditEvent) []models.AuditEvent {
+	if callerHasPermission(c, models.PermUsersSalaryRead) {
+		return events
+	}
+	for _, event := range events {
+		delete(event.Changes, "salary")
+	}
+	return events
+}
+
+// Search changes to employee records, newest first (admin only). Filters:
+// actor (also matches the admin behind an impersonation), action (comma
+// separated), user_id, field, request_id, from and to.
+func GetAuditEvents(c *gin.Context) {
+	page, limit, ok := paginationParams(c)
+	if !ok {
+		return
+	}
+
+	filters := models.AuditEventFilters{
+		Actor:     c.Query("actor"),
+		Field:     c.Query("field"),
+		RequestID: c.Query("request_id"),
+	}
+	if action := c.Query("action"); action != "" {
+		filters.Actions = strings.Split(action, ",")
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.Atoi(userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		filters.TargetID = &id
+	}
+	if filters.Field == "salary" && !callerHasPermission(c, models.PermUsersSalaryRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to filter by salary"})
+		return
+	}
+
+	for _, param := range []string{"from", "to"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeParam(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s value", param)})
+			return
+		}
+		if param == "from" {
+			filters.From = &t
+		} else {
+			filters.To = &t
+		}
+	}
+
+	events, total, err := models.GetAuditEvents(filters, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": auditEventsForCaller(c, events),
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// List the changes made to one employee record, newest first. The history of
+// deleted and purged records stays available.
+func GetUserHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	page, limit, ok := paginationParams(c)
+	if !ok {
+		return
+	}
+
+	events, total, err := models.GetAuditEvents(models.AuditEventFilters{TargetID: &id}, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		return
+	}
+	if total == 0 {
+		if _, err := models.GetUserByID(id); err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": auditEventsForCaller(c, events),
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
@@ -5,14 +5,16 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"time"
+	"strings"
+	"sync"
 
 	"admin-dashboard/database"
+	"admin-dashboard/middleware"
+	"admin-dashboard/models"
+	"admin-dashboard/password"
+	"admin-dashboard/utils"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv" // Import godotenv package
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Define a struct to hold user login data (email and password)
@@ -23,24 +25,33 @@ type LoginInput struct {
 
 // User struct for storing user data from DB (for authentication)
 type User struct {
-	ID           int    `json:"id"`
-	Email        string `json:"email"`
-	PasswordHash string `json:"password_hash"`
+	ID           int           `json:"id"`
+	Email        string        `json:"email"`
+	PasswordHash string        `json:"password_hash"`
+	Role         string        `json:"role"`
+	TOTPEnabled  bool          `json:"totp_enabled"`
+	UserID       sql.NullInt64 `json:"-"` // linked employee record, if any
+	IsActive     bool          `json:"is_active"`
 }
 
-// Load environment variables from the .env file
-func init() {
-	err := godotenv.Load()
+// Hash compared against when an email is unknown, so the response takes as
+// long as for a real account. Created on first use with the configured parameters.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := password.Hash("dummy-password")
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		log.Printf("Error creating dummy password hash: %v", err)
 	}
-}
+	return hash
+})
 
 // Function to retrieve user by email from the database
 func getUserByEmail(email string) (*User, error) {
 	var user User
 	// Query the database to find the user by email
-	err := database.DB.QueryRow("SELECT id, email, password_hash FROM credentials WHERE email = $1", email).Scan(&user.ID, &user.Email, &user.PasswordHash)
+	err := database.DB.QueryRow(`
+		SELECT c.id, c.email, c.password_hash, r.name, c.totp_enabled, c.user_id, c.is_active
+		FROM credentials c JOIN roles r ON r.id = c.role_id
+		WHERE c.email = $1`, email).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.TOTPEnabled, &user.UserID, &user.IsActive)
 	if err != nil {
 		return nil, err
 	}
@@ -53,31 +64,78 @@ type RegisterUserInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// Function to check whether self-registration is enabled (OPEN_REGISTRATION, off by default)
+func openRegistrationEnabled() bool {
+	return utils.GetBoolEnv("OPEN_REGISTRATION", false)
+}
+
+// Function to check an email against REGISTRATION_ALLOWED_DOMAINS (comma-separated, empty allows all)
+func registrationDomainAllowed(email string) bool {
+	allowed := os.Getenv("REGISTRATION_ALLOWED_DOMAINS")
+	if allowed == "" {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range strings.Split(allowed, ",") {
+		if strings.ToLower(strings.TrimSpace(d)) == domain {
+			return true
+		}
+	}
+	return false
+}
+
 // Function to register a user
 func RegisterUser(c *gin.Context) {
 	var input RegisterUserInput
 
+	// Employees are normally invited by an admin; self-registration is opt-in
+	if !openRegistrationEnabled() {
+		c.JSON(403, gin.H{"error": "Self-registration is disabled. Please ask an administrator for an invitation."})
+		return
+	}
+
 	// Parse the incoming JSON request body
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(400, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	// Check if the email is "admin@gmail.com"
-	if input.Email == "admin@gmail.com" {
-		c.JSON(403, gin.H{"error": "Registration using admin@gmail.com is not allowed"})
+	// Only employees without an account may register. Both refusals get the
+	// same answer so this endpoint cannot be used to find accounts.
+	var allowed bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)
+		   AND NOT EXISTS (SELECT 1 FROM credentials WHERE email = $1)`, input.Email).Scan(&allowed)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Error checking email"})
+		return
+	}
+	if !allowed || !registrationDomainAllowed(input.Email) {
+		c.JSON(400, gin.H{"error": "Registration is not possible for this email. If you already have an account, please log in."})
+		return
+	}
+
+	if err := validatePassword(input.Password, input.Email); err != nil {
+		respondPasswordError(c, err)
 		return
 	}
 
-	// Hash the password using bcrypt
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	// Hash the password
+	hashedPassword, err := password.Hash(input.Password)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Error hashing the password"})
 		return
 	}
 
-	// Save the user with hashed password to the database
-	_, err = database.DB.Exec("INSERT INTO credentials (email, password_hash) VALUES ($1, $2)", input.Email, string(hashedPassword))
+	// Save the user with hashed password to the database. Self-registered
+	// accounts always start as employees; admins can promote them later.
+	_, err = database.DB.Exec(`
+		INSERT INTO credentials (email, password_hash, role_id, user_id)
+		VALUES ($1, $2, (SELECT id FROM roles WHERE name = $3), (SELECT id FROM users WHERE email = $1))`, input.Email, hashedPassword, models.RoleEmployee)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Error inserting user into the database"})
 		return
@@ -87,6 +145,28 @@ func RegisterUser(c *gin.Context) {
 	c.JSON(200, gin.H{"message": "User registered successfully"})
 }
 
+// Response given to callers who may not learn whether an email is registered
+const uniformEmailCheckMessage = "Email checks are only available to signed-in staff"
+
+// Function to check whether the signed-in caller holds a permission
+func callerHasPermission(c *gin.Context, permission string) bool {
+	granted, err := middleware.HasPermission(c, permission)
+	if err != nil {
+		log.Printf("Error checking permission %s for role %s: %v", permission, c.GetString("role"), err)
+		return false
+	}
+	return granted
+}
+
+// Function to get a name for the caller to record in created_by/invited_by
+// columns: the account email, or the service account name for its tokens
+func callerName(c *gin.Context) string {
+	if name := c.GetString("service_account"); name != "" {
+		return name
+	}
+	return c.GetString("email")
+}
+
 // Check if the email already exists in the database
 func CheckEmail(c *gin.Context) {
 	var input struct {
@@ -99,6 +179,12 @@ func CheckEmail(c *gin.Context) {
 		return
 	}
 
+	// Only signed-in staff who can read users may learn whether an account exists
+	if !callerHasPermission(c, models.PermUsersRead) {
+		c.JSON(200, gin.H{"message": uniformEmailCheckMessage})
+		return
+	}
+
 	// Query to check if email exists in the credentials table
 	var exists bool
 	err := database.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM credentials WHERE email = $1)", input.Email).Scan(&exists)
@@ -123,6 +209,12 @@ func CheckEmailExists(c *gin.Context) {
 		return
 	}
 
+	// Only signed-in staff who can read users may learn whether an employee exists
+	if !callerHasPermission(c, models.PermUsersRead) {
+		c.JSON(200, gin.H{"message": uniformEmailCheckMessage})
+		return
+	}
+
 	// Query to check if email exists in the database
 	var exists bool
 	err := database.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)", input.Email).Scan(&exists)
@@ -149,8 +241,8 @@ func ChangePassword(c *gin.Context) {
 	}
 
 	// Get email from context
-	email, exists := c.Get("email")
-	if !exists {
+	email := c.GetString("email")
+	if email == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email not found in context"})
 		return
 	}
@@ -164,21 +256,27 @@ func ChangePassword(c *gin.Context) {
 	}
 
 	// Check if old password matches the stored hash
-	err = bcrypt.CompareHashAndPassword([]byte(storedPasswordHash), []byte(input.OldPassword))
-	if err != nil {
+	match, _, err := password.Verify(storedPasswordHash, input.OldPassword)
+	if err != nil || !match {
 		c.JSON(400, gin.H{"error": "Old password is incorrect"})
 		return
 	}
 
+	// Check the new password against the policy, including password reuse
+	if err := validatePassword(input.NewPassword, email); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
 	// Hash the new password
-	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	hashedNewPassword, err := password.Hash(input.NewPassword)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Error hashing the new password"})
 		return
 	}
 
-	// Update the password in the database
-	_, err = database.DB.Exec("UPDATE credentials SET password_hash = $1 WHERE email = $2", hashedNewPassword, email)
+	// Update the password in the database, remembering the old one
+	err = models.UpdatePassword(email, hashedNewPassword, password.DefaultPolicy.HistorySize)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Error updating the password"})
 		return
@@ -194,7 +292,12 @@ func GetUserEmail(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email not found"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"email": email})
+	response := gin.H{"email": email, "impersonating": false}
+	if impersonator := c.GetString("impersonator"); impersonator != "" {
+		response["impersonating"] = true
+		response["impersonated_by"] = impersonator
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // Handle login route
@@ -207,102 +310,162 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Slow down and lock out repeated guessing
+	if rejectThrottledLogin(c, input.Email) {
+		recordLoginEvent(c, input.Email, models.LoginMethodPassword, false, "throttled")
+		return
+	}
+
 	// Retrieve user from the database by email
 	user, err := getUserByEmail(input.Email)
 	if err != nil {
+		// Spend the same time as a wrong password so unknown emails cannot be told apart
+		password.Verify(dummyPasswordHash(), input.Password)
+		recordLoginFailure(input.Email, c.ClientIP())
+		recordLoginEvent(c, input.Email, models.LoginMethodPassword, false, "unknown_account")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Compare the hashed password with the input password
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password))
-	if err != nil {
+	match, needsRehash, err := password.Verify(user.PasswordHash, input.Password)
+	if err != nil || !match {
+		if err != nil {
+			log.Printf("Error verifying password of %s: %v", user.Email, err)
+		}
+		recordLoginFailure(input.Email, c.ClientIP())
+		recordLoginEvent(c, input.Email, models.LoginMethodPassword, false, "invalid_password")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	if input.Email != "admin@gmail.com" {
-		// Fetch user details from the users table
-		var userDetails struct {
-			First_Name          string `json:"first_name"`
-			Last_Name           string `json:"last_name"`
-			Gender              string `json:"gender"`
-			Location            string `json:"location"`
-			Email               string `json:"email"`
-			Phone               string `json:"phone"`
-			Department          string `json:"department"`
-			Role                string `json:"role"`
-			Salary              int    `json:"salary"`
-			Join_Date           string `json:"join_date"`
-			Years_of_Experience int    `json:"years_of_experience"`
-		}
-		err = database.DB.QueryRow(`
-			SELECT first_name, last_name, gender, location, email, phone, department, role, salary, join_date, years_of_experience
-			FROM users WHERE email = $1`, input.Email).Scan(
-			&userDetails.First_Name, &userDetails.Last_Name, &userDetails.Gender, &userDetails.Location, &userDetails.Email, &userDetails.Phone,
-			&userDetails.Department, &userDetails.Role, &userDetails.Salary, &userDetails.Join_Date, &userDetails.Years_of_Experience)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			}
-			return
-		}
+	// Hashes from older schemes or with outdated parameters are replaced while
+	// the plain password is at hand
+	if needsRehash {
+		rehashPassword(user, input.Password)
+	}
 
-		// Retrieve the secret key from the environment variables
-		SecretKey := []byte(os.Getenv("SECRET_KEY"))
-		if SecretKey == nil || len(SecretKey) == 0{
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Secret key not found"})
-			return
-		}
+	// Only reveal the account state once the password has been proven
+	if !user.IsActive {
+		recordLoginEvent(c, user.Email, models.LoginMethodPassword, false, "account_deactivated")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return
+	}
 
-		// Create the JWT token
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"email": input.Email,
-			"exp":   time.Now().Add(time.Hour * 1).Unix(), // Token expiration time (1 hour)
-		})
+	continueLogin(c, user, models.LoginMethodPassword)
+}
+
+// Function to store a fresh hash of a verified password. Failures are only
+// logged; the old hash keeps working.
+func rehashPassword(user *User, plain string) {
+	hash, err := password.Hash(plain)
+	if err == nil {
+		err = models.UpgradePasswordHash(user.Email, user.PasswordHash, hash)
+	}
+	if err != nil {
+		log.Printf("Error upgrading password hash of %s: %v", user.Email, err)
+	}
+}
 
-		// Sign the token with the secret key
-		tokenString, err := token.SignedString(SecretKey)
+// Function to finish a login whose first factor (password or sign-in link)
+// has been checked. Accounts with two-factor authentication get a short-lived
+// challenge instead of a session; /login/mfa exchanges it for the real tokens.
+func continueLogin(c *gin.Context, user *User, method string) {
+	if user.TOTPEnabled {
+		challenge, err := generateMFAChallenge(user.Email)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
 			return
 		}
-		// Extract the email from the token claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || claims["email"] == nil {
-		}
-
-		// Respond with token and user details
 		c.JSON(http.StatusOK, gin.H{
-			"token":     tokenString,
-			"user_data": userDetails,
-			"email":     input.Email,
+			"mfa_required": true,
+			"mfa_token":    challenge,
+			"expires_in":   int(mfaChallengeTTL.Seconds()),
 		})
-	} else {
+		return
+	}
 
-		// Retrieve the secret key from the environment variables
-		SecretKey := []byte(os.Getenv("SECRET_KEY"))
-		if SecretKey == nil || len(SecretKey) == 0 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Secret key not found"})
-			return
-		}
+	completeLogin(c, user, method)
+}
 
-		// Create the JWT token
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"email": input.Email,
-			"exp":   time.Now().Add(time.Hour * 1).Unix(), // Token expiration time (1 hour)
-		})
+// Employee record returned to the frontend on login
+type loginUserData struct {
+	First_Name          string `json:"first_name"`
+	Last_Name           string `json:"last_name"`
+	Gender              string `json:"gender"`
+	Location            string `json:"location"`
+	Email               string `json:"email"`
+	Phone               string `json:"phone"`
+	Department          string `json:"department"`
+	Role                string `json:"role"`
+	Salary              int    `json:"salary"`
+	Join_Date           string `json:"join_date"`
+	Years_of_Experience int    `json:"years_of_experience"`
+}
 
-		// Sign the token with the secret key
-		tokenString, err := token.SignedString(SecretKey)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
-			return
-		}
+// Function to fetch the employee record linked to an account. Admins are
+// bootstrapped from the environment and may not have one (nil is returned);
+// for other accounts a missing record is sql.ErrNoRows.
+func getLoginUserData(user *User) (*loginUserData, error) {
+	var userDetails loginUserData
+	err := database.DB.QueryRow(`
+		SELECT first_name, last_name, gender, location, email, phone, department, role, salary, join_date, years_of_experience
+		FROM users WHERE id = $1`, user.UserID).Scan(
+		&userDetails.First_Name, &userDetails.Last_Name, &userDetails.Gender, &userDetails.Location, &userDetails.Email, &userDetails.Phone,
+		&userDetails.Department, &userDetails.Role, &userDetails.Salary, &userDetails.Join_Date, &userDetails.Years_of_Experience)
+	if err == sql.ErrNoRows && user.Role == models.RoleAdmin {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &userDetails, nil
+}
 
-		// Return the token in the response
-		c.JSON(http.StatusOK, gin.H{"token": tokenString})
+// Function to issue tokens and respond with the account's details once every
+// login factor has been checked. method is recorded in the login history.
+func completeLogin(c *gin.Context, user *User, method string) {
+	if !user.IsActive {
+		recordLoginEvent(c, user.Email, method, false, "account_deactivated")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return
+	}
+
+	clearLoginFailures(user.Email)
+
+	permissions, err := models.GetRolePermissions(user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
 	}
+
+	userDetails, err := getLoginUserData(user)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	tokenString, refreshToken, err := issueTokens(c, user.Email, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+	recordLoginEvent(c, user.Email, method, true, "")
+
+	response := gin.H{
+		"token":         tokenString,
+		"refresh_token": refreshToken,
+		"expires_in":    int(accessTokenTTL().Seconds()),
+		"email":         user.Email,
+		"role":          user.Role,
+		"permissions":   permissions,
+	}
+	if userDetails != nil {
+		response["user_data"] = userDetails
+	}
+
+	// Respond with token and user details
+	c.JSON(http.StatusOK, response)
 }
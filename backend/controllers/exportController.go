@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/utils"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Number of rows written between flushes, so the download makes progress
+const exportFlushRows = 500
+
+// A column an export can contain and how to get its value from a record.
+// Values are strings, ints or times so every format can type them.
+type exportColumn struct {
+	name  string
+	value func(user *models.Users) interface{}
+}
+
+// Columns of an export, in the order they are written by default
+var exportColumns = []exportColumn{
+	{"id", func(u *models.Users) interface{} { return u.ID }},
+	{"first_name", func(u *models.Users) interface{} { return u.First_Name }},
+	{"last_name", func(u *models.Users) interface{} { return u.Last_Name }},
+	{"gender", func(u *models.Users) interface{} { return u.Gender }},
+	{"location", func(u *models.Users) interface{} { return u.Location }},
+	{"email", func(u *models.Users) interface{} { return u.Email }},
+	{"phone", func(u *models.Users) interface{} { return u.Phone }},
+	{"department", func(u *models.Users) interface{} { return u.Department }},
+	{"role", func(u *models.Users) interface{} { return u.Role }},
+	{"salary", func(u *models.Users) interface{} { return u.Salary }},
+	{"join_date", func(u *models.Users) interface{} { return exportDate(u.Join_Date) }},
+	{"years_of_experience", func(u *models.Users) interface{} { return u.Years_of_Experience }},
+	{"created_at", func(u *models.Users) interface{} { return u.CreatedAt.UTC() }},
+	{"updated_at", func(u *models.Users) interface{} { return u.UpdatedAt.UTC() }},
+}
+
+// Function to read a join date as scanned from the database ("2023-03-15" or
+// "2023-03-15T00:00:00Z") as a date, or leave it as text if it is neither
+func exportDate(value string) interface{} {
+	if len(value) >= 10 {
+		if date, err := time.Parse("2006-01-02", value[:10]); err == nil {
+			return date
+		}
+	}
+	return value
+}
+
+// Function to read the columns parameter (columns=id,email or
+// columns[]=id&columns[]=email). Without it every column the caller may see
+// is exported. Responds 400 for unknown columns, 403 for salary without
+// users:salary:read, and returns false.
+func exportColumnsParam(c *gin.Context) ([]exportColumn, bool) {
+	canReadSalary := callerHasPermission(c, models.PermUsersSalaryRead)
+	names := c.QueryArray("columns[]")
+	if len(names) == 0 {
+		if param := c.Query("columns"); param != "" {
+			names = strings.Split(param, ",")
+		}
+	}
+
+	if len(names) == 0 {
+		columns := []exportColumn{}
+		for _, column := range exportColumns {
+			if column.name != "salary" || canReadSalary {
+				columns = append(columns, column)
+			}
+		}
+		return columns, true
+	}
+
+	byName := map[string]exportColumn{}
+	for _, column := range exportColumns {
+		byName[column.name] = column
+	}
+	columns := []exportColumn{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		column, ok := byName[name]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown column: " + name})
+			return nil, false
+		}
+		if name == "salary" && !canReadSalary {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to export salaries"})
+			return nil, false
+		}
+		seen[name] = true
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No columns to export"})
+		return nil, false
+	}
+	return columns, true
+}
+
+// Numbers and phone numbers, which may start with + or - without being formulas
+var (
+	csvNumberPattern = regexp.MustCompile(`^[+-]?[0-9]+(\.[0-9]+)?$`)
+	csvPhonePattern  = regexp.MustCompile(`^\+?[0-9 ()-]+$`)
+)
+
+// Function to protect a CSV cell from being run as a formula by spreadsheet
+// programs (text starting with =, +, -, @, a tab or a carriage return is
+// prefixed with '). Numbers and phone numbers are left as they are.
+func csvCell(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		if v == "" || !strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return v
+		}
+		if csvNumberPattern.MatchString(v) || csvPhonePattern.MatchString(v) {
+			return v
+		}
+		return "'" + v
+	case time.Time:
+		if v.Equal(v.Truncate(24 * time.Hour)) {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// Writer of one export format; rows go to the response as they are written
+type exportWriter interface {
+	header(columns []exportColumn) error
+	row(values []interface{}) error
+	flush() error // sends the buffered rows
+	close() error
+}
+
+type csvExportWriter struct{ writer *csv.Writer }
+
+func (w *csvExportWriter) header(columns []exportColumn) error {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+	return w.writer.Write(names)
+}
+
+func (w *csvExportWriter) row(values []interface{}) error {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = csvCell(value)
+	}
+	return w.writer.Write(cells)
+}
+
+func (w *csvExportWriter) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvExportWriter) close() error { return w.flush() }
+
+type xlsxExportWriter struct{ writer *utils.XLSXWriter }
+
+func (w *xlsxExportWriter) header(columns []exportColumn) error {
+	names := make([]interface{}, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+	return w.writer.WriteRow(names)
+}
+
+func (w *xlsxExportWriter) row(values []interface{}) error { return w.writer.WriteRow(values) }
+
+func (w *xlsxExportWriter) flush() error { return nil } // the archive compresses in blocks
+
+func (w *xlsxExportWriter) close() error { return w.writer.Close() }
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+	names   []string
+}
+
+func (w *ndjsonExportWriter) header(columns []exportColumn) error {
+	for _, column := range columns {
+		w.names = append(w.names, column.name)
+	}
+	return nil
+}
+
+func (w *ndjsonExportWriter) row(values []interface{}) error {
+	record := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		if date, ok := value.(time.Time); ok && w.names[i] == "join_date" {
+			value = date.Format("2006-01-02")
+		}
+		record[w.names[i]] = value
+	}
+	return w.encoder.Encode(record)
+}
+
+func (w *ndjsonExportWriter) flush() error { return nil }
+
+func (w *ndjsonExportWriter) close() error { return nil }
+
+// Download the employees matching the filters of GET /users, all of them
+// rather than a page, as format=csv (default), xlsx or ndjson. columns picks
+// the columns and their order. The file is written as the records are read.
+func ExportUsers(c *gin.Context) {
+	filters, ok := userFiltersParam(c)
+	if !ok {
+		return
+	}
+	columns, ok := exportColumnsParam(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	var contentType string
+	var writer exportWriter
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+		writer = &csvExportWriter{writer: csv.NewWriter(c.Writer)}
+	case "xlsx":
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+		writer = &ndjsonExportWriter{encoder: json.NewEncoder(c.Writer)}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, xlsx or ndjson"})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="users-%s.%s"`, time.Now().UTC().Format("20060102"), format))
+	c.Status(http.StatusOK)
+	if format == "xlsx" {
+		xlsx, err := utils.NewXLSXWriter(c.Writer)
+		if err != nil {
+			log.Printf("Error exporting users: %v", err)
+			return
+		}
+		writer = &xlsxExportWriter{writer: xlsx}
+	}
+
+	// The status is sent with the first bytes, so a failure part way can
+	// only cut the file short; it is logged
+	rows := 0
+	err := writer.header(columns)
+	if err == nil {
+		err = models.EachUser(filters, func(user *models.Users) error {
+			values := make([]interface{}, len(columns))
+			for i, column := range columns {
+				values[i] = column.value(user)
+			}
+			if err := writer.row(values); err != nil {
+				return err
+			}
+			if rows++; rows%exportFlushRows == 0 {
+				if err := writer.flush(); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = writer.close()
+	}
+	if err != nil {
+		log.Printf("Error exporting users: %v", err)
+		c.Abort()
+		return
+	}
+
+	log.Printf("%s exported %d users as %s", callerName(c), rows, format)
+}
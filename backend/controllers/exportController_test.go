@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"=1+1", "'=1+1"},
+		{"+SUM(A1:A2)", "'+SUM(A1:A2)"},
+		{"-1+cmd|' /C calc'!A0", "'-1+cmd|' /C calc'!A0"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"-2.5", "-2.5"},
+		{"+42", "+42"},
+		{"+1 (555) 010-0100", "+1 (555) 010-0100"},
+		{"555-0100", "555-0100"},
+		{"Sales", "Sales"},
+		{"", ""},
+		{50000, "50000"},
+		{time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC), "2023-03-15"},
+		{time.Date(2023, 3, 15, 9, 30, 0, 0, time.UTC), "2023-03-15T09:30:00Z"},
+	}
+	for _, test := range tests {
+		if got := csvCell(test.value); got != test.want {
+			t.Errorf("csvCell(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
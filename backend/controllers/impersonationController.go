@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/utils"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Longest impersonation allowed, whatever IMPERSONATION_TTL says
+const maxImpersonationTTL = time.Hour
+
+// Function to get how long an impersonation token lasts (IMPERSONATION_TTL,
+// default 15m). It cannot be refreshed, so this is a hard limit.
+func impersonationTTL() time.Duration {
+	ttl := utils.GetDurationEnv("IMPERSONATION_TTL", 15*time.Minute)
+	if ttl > maxImpersonationTTL {
+		return maxImpersonationTTL
+	}
+	return ttl
+}
+
+// Function to create an access token for subject that records actor as the
+// one really acting ("act" claim, RFC 8693) and the impersonation it belongs to
+func generateImpersonationToken(subject *User, actor, jti string, impersonationID int, expiresAt time.Time) (string, error) {
+	return signClaims(jwt.MapClaims{
+		"typ":   "access",
+		"email": subject.Email,
+		"role":  subject.Role,
+		"jti":   jti,
+		"imp":   impersonationID,
+		"act":   map[string]interface{}{"sub": actor},
+		"iat":   time.Now().Unix(),
+		"exp":   expiresAt.Unix(),
+	})
+}
+
+// Start seeing the dashboard as an employee (admin only). The token carries
+// both accounts, expires after IMPERSONATION_TTL and every request made with
+// it is recorded.
+func ImpersonateUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason" binding:"required,max=255"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	actor := c.GetString("email")
+	if c.GetInt("impersonation_id") != 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not available while impersonating"})
+		return
+	}
+
+	email, err := models.GetUserEmailByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	subject, err := getUserByEmail(email)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no login account"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	switch {
+	case subject.Email == actor:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot impersonate yourself"})
+		return
+	case subject.Role == models.RoleAdmin:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins cannot be impersonated"})
+		return
+	case !subject.IsActive:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Account is deactivated"})
+		return
+	}
+
+	permissions, err := models.GetRolePermissions(subject.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	userDetails, err := getLoginUserData(subject)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	jti, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+	ttl := impersonationTTL()
+	expiresAt := time.Now().Add(ttl)
+	impersonationID, err := models.CreateImpersonation(actor, subject.Email, id, input.Reason, jti, c.ClientIP(), expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start impersonation"})
+		return
+	}
+	token, err := generateImpersonationToken(subject, actor, jti, impersonationID, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+
+	log.Printf("%s started impersonating %s (impersonation %d): %s", actor, subject.Email, impersonationID, input.Reason)
+
+	response := gin.H{
+		"token":           token,
+		"expires_in":      int(ttl.Seconds()),
+		"expires_at":      expiresAt,
+		"email":           subject.Email,
+		"role":            subject.Role,
+		"permissions":     permissions,
+		"impersonated_by": actor,
+		"impersonation":   impersonationID,
+	}
+	if userDetails != nil {
+		response["user_data"] = userDetails
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Stop impersonating: revoke the impersonation token used for this request
+func EndImpersonation(c *gin.Context) {
+	id := c.GetInt("impersonation_id")
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not impersonating"})
+		return
+	}
+
+	if err := models.EndImpersonation(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end impersonation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Impersonation ended"})
+}
+
+// List impersonations, optionally of one employee (?user_id=), newest first (admin only)
+func GetImpersonations(c *gin.Context) {
+	page, limit, ok := paginationParams(c)
+	if !ok {
+		return
+	}
+
+	var userID *int
+	if param := c.Query("user_id"); param != "" {
+		id, err := strconv.Atoi(param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		userID = &id
+	}
+
+	impersonations, total, err := models.GetImpersonations(userID, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch impersonations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"impersonations": impersonations,
+		"total":          total,
+		"page":           page,
+		"limit":          limit,
+	})
+}
+
+// Get one impersonation with every request made during it (admin only)
+func GetImpersonation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid impersonation ID"})
+		return
+	}
+
+	impersonation, err := models.GetImpersonation(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Impersonation not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch impersonation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, impersonation)
+}
@@ -0,0 +1,296 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/utils"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of one import
+const (
+	maxImportBytes = 10 << 20
+	maxImportRows  = 5000
+)
+
+// Ways of committing an import
+const (
+	importAllOrNothing = "all_or_nothing" // nothing is created if any row fails
+	importBestEffort   = "best_effort"    // the valid rows are created
+)
+
+// Fields of an employee record an import fills, in the order they are reported
+var importFields = []string{
+	"first_name", "last_name", "gender", "location", "email", "phone",
+	"department", "role", "salary", "join_date", "years_of_experience",
+}
+
+// Outcome of one spreadsheet row
+type importRowReport struct {
+	Row    int      `json:"row"`    // as numbered in the spreadsheet, the header being row 1
+	Status string   `json:"status"` // created, valid (would be created) or failed
+	ID     int      `json:"id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Function to read an uploaded CSV or XLSX file (by extension, or by content
+// when the extension is neither) into rows of text
+func readImportRows(filename string, data []byte) ([][]string, error) {
+	format := strings.ToLower(filepath.Ext(filename))
+	if format != ".csv" && format != ".xlsx" {
+		format = ".csv"
+		if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+			format = ".xlsx"
+		}
+	}
+
+	if format == ".xlsx" {
+		return utils.ReadXLSX(bytes.NewReader(data), int64(len(data)), maxImportRows+1)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = false
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+		if len(rows) > maxImportRows {
+			return nil, fmt.Errorf("more than %d rows", maxImportRows)
+		}
+		rows = append(rows, record)
+	}
+	return rows, nil
+}
+
+// Function to normalise a column header for matching ("Join Date" matches join_date)
+func normalizeImportHeader(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	return strings.NewReplacer(" ", "_", "-", "_", ".", "_").Replace(header)
+}
+
+// Function to find the column of each field: the header named in mapping
+// (field -> header), or else the header with the field's name. Returns the
+// column indexes and the problems with the mapping.
+func importColumns(headers []string, mapping map[string]string) (map[string]int, []string) {
+	byHeader := map[string]int{}
+	for i, header := range headers {
+		if _, seen := byHeader[normalizeImportHeader(header)]; !seen {
+			byHeader[normalizeImportHeader(header)] = i
+		}
+	}
+
+	var problems []string
+	known := map[string]bool{}
+	for _, field := range importFields {
+		known[field] = true
+	}
+	for field := range mapping {
+		if !known[field] {
+			problems = append(problems, fmt.Sprintf("unknown field %q in mapping", field))
+		}
+	}
+
+	columns := map[string]int{}
+	for _, field := range importFields {
+		if header, ok := mapping[field]; ok {
+			index, found := byHeader[normalizeImportHeader(header)]
+			if !found {
+				problems = append(problems, fmt.Sprintf("column %q for %s not found", header, field))
+				continue
+			}
+			columns[field] = index
+		} else if index, found := byHeader[field]; found {
+			columns[field] = index
+		} else {
+			problems = append(problems, fmt.Sprintf("no column for %s", field))
+		}
+	}
+	return columns, problems
+}
+
+// Function to turn an error of models.ImportUsers for one row into a message for the report
+func importRowError(email string, err error) string {
+	switch err.Error() {
+	case "email already exists", "phone already exists", "email belongs to a deleted user", "email belongs to a login account":
+		return err.Error()
+	}
+	log.Printf("Error importing user %s: %v", email, err)
+	return "could not be saved"
+}
+
+// Create employees from a CSV or XLSX file (multipart field "file"). The
+// first row holds the column headers; "mapping" (JSON, field -> header)
+// names the column of each field whose header is not the field name. Each
+// row is checked with parseNewUser like POST /users, and its problems are
+// listed in the report. With dry_run=true nothing is created and
+// the report shows what would happen; mode=best_effort creates the valid
+// rows even if others fail (default all_or_nothing).
+func ImportUsers(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes+1<<20)
+
+	dryRun := false
+	if value := c.PostForm("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run value"})
+			return
+		}
+		dryRun = parsed
+	}
+	mode := c.DefaultPostForm("mode", importAllOrNothing)
+	if mode != importAllOrNothing && mode != importBestEffort {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be all_or_nothing or best_effort"})
+		return
+	}
+	mapping := map[string]string{}
+	if value := c.PostForm("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mapping must be a JSON object of field names to column headers"})
+			return
+		}
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV or XLSX file is required"})
+		return
+	}
+	if fileHeader.Size > maxImportBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("The file must be at most %d MB", maxImportBytes>>20)})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the file"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the file"})
+		return
+	}
+
+	rows, err := readImportRows(fileHeader.Filename, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the file: " + err.Error()})
+		return
+	}
+	if len(rows) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The file needs a header row and at least one employee"})
+		return
+	}
+
+	headers := rows[0]
+	columns, problems := importColumns(headers, mapping)
+	if len(problems) > 0 {
+		// The client shows the headers so the user can map them and retry
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      "The columns could not be mapped to employee fields",
+			"violations": problems,
+			"columns":    headers,
+			"fields":     importFields,
+		})
+		return
+	}
+
+	reports := []importRowReport{}
+	var newUsers []models.NewUser
+	var newUserReports []int // index in reports of each entry of newUsers
+	validationFailed := false
+	for i, row := range rows[1:] {
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue // blank lines between employees
+		}
+		cell := func(field string) string {
+			if index := columns[field]; index < len(row) {
+				return strings.TrimSpace(row[index])
+			}
+			return ""
+		}
+		newUser, violations := parseNewUser(newUserInput{
+			First_Name: cell("first_name"), Last_Name: cell("last_name"), Gender: cell("gender"), Location: cell("location"),
+			Email: cell("email"), Phone: cell("phone"), Department: cell("department"), Role: cell("role"),
+			Salary: cell("salary"), Join_Date: cell("join_date"), Years_of_Experience: cell("years_of_experience"),
+		})
+
+		report := importRowReport{Row: i + 2, Status: "valid"}
+		if len(violations) > 0 {
+			report.Status, report.Errors = "failed", violations
+			validationFailed = true
+		} else {
+			newUsers = append(newUsers, newUser)
+			newUserReports = append(newUserReports, len(reports))
+		}
+		reports = append(reports, report)
+	}
+	if len(reports) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The file needs a header row and at least one employee"})
+		return
+	}
+
+	// Rows that passed validation are also tried against the database (e.g.
+	// for taken emails), but not committed if the import cannot succeed as a whole
+	allOrNothing := mode == importAllOrNothing
+	var ids []int
+	var rowErrors []error
+	committed := false
+	if len(newUsers) > 0 {
+		ids, rowErrors, committed, err = models.ImportUsers(auditActor(c), newUsers, allOrNothing, dryRun || (allOrNothing && validationFailed))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import users"})
+			return
+		}
+	}
+
+	created, failed := 0, 0
+	for i, index := range newUserReports {
+		if rowErrors[i] != nil {
+			reports[index].Status = "failed"
+			reports[index].Errors = []string{importRowError(newUsers[i].Email, rowErrors[i])}
+		} else if committed {
+			reports[index].Status, reports[index].ID = "created", ids[i]
+			created++
+		}
+	}
+	for _, report := range reports {
+		if report.Status == "failed" {
+			failed++
+		}
+	}
+	if created > 0 {
+		log.Printf("%s imported %d users", callerName(c), created)
+	}
+
+	status := http.StatusOK
+	if created > 0 {
+		status = http.StatusCreated
+	} else if !dryRun && failed > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{
+		"dry_run":   dryRun,
+		"mode":      mode,
+		"committed": committed,
+		"total":     len(reports),
+		"created":   created,
+		"failed":    failed,
+		"columns":   headers,
+		"rows":      reports,
+	})
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function to post a CSV file to ImportUsers with the given form fields
+func postImport(t *testing.T, csv string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	file, err := form.CreateFormFile("file", "employees.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte(csv))
+	form.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/users/import", ImportUsers)
+	request := httptest.NewRequest(http.MethodPost, "/users/import", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestImportUsersReportsInvalidRows(t *testing.T) {
+	csv := "First Name,Last Name,Gender,Location,Email,Phone,Department,Role,Salary,Join Date,Years of Experience\n" +
+		"Jo,Link,Other,Remote,not-an-email,555-0100,Sales,Agent,50000,2023-03-15,3\n" +
+		",,,,,,,,,,\n" + // blank row
+		"Sam,,Male,Remote,sam@example.com,555-0101,Sales,Agent,-5,15/03/2023,2\n"
+	response := postImport(t, csv, map[string]string{"dry_run": "true"})
+	if response.Code != http.StatusOK {
+		t.Fatalf("got %d %s", response.Code, response.Body)
+	}
+
+	var report struct {
+		Committed bool              `json:"committed"`
+		Total     int               `json:"total"`
+		Failed    int               `json:"failed"`
+		Rows      []importRowReport `json:"rows"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Committed || report.Total != 2 || report.Failed != 2 {
+		t.Errorf("committed %v, total %d, failed %d", report.Committed, report.Total, report.Failed)
+	}
+	want := []importRowReport{
+		{Row: 2, Status: "failed", Errors: []string{"email is not a valid email address"}},
+		{Row: 4, Status: "failed", Errors: []string{
+			"join_date must be a date (YYYY-MM-DD)", "last_name cannot be empty", "salary cannot be negative",
+		}},
+	}
+	if !reflect.DeepEqual(report.Rows, want) {
+		t.Errorf("rows = %+v, want %+v", report.Rows, want)
+	}
+
+	// Without dry_run nothing can be created either
+	if response := postImport(t, csv, nil); response.Code != http.StatusUnprocessableEntity {
+		t.Errorf("import of invalid rows: got %d %s", response.Code, response.Body)
+	}
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"admin-dashboard/mailer"
+	"admin-dashboard/models"
+	"admin-dashboard/password"
+	"admin-dashboard/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Function to get how long invitation links stay valid (INVITATION_TTL, default 72h)
+func invitationTTL() time.Duration {
+	return utils.GetDurationEnv("INVITATION_TTL", 72*time.Hour)
+}
+
+// Function to create a signed invitation link for an employee and email it to them
+func sendInvitation(userID int, email, invitedBy string) error {
+	jti, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return err
+	}
+
+	ttl := invitationTTL()
+	expiresAt := time.Now().Add(ttl)
+	token, err := signClaims(jwt.MapClaims{
+		"typ":   "invite",
+		"jti":   jti,
+		"email": email,
+		"exp":   expiresAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := models.CreateInvitation(userID, email, jti, invitedBy, expiresAt); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/accept-invite?token=%s", frontendURL(), url.QueryEscape(token))
+	body := fmt.Sprintf("You have been invited to the Admin Dashboard.\n\n"+
+		"Open this link to choose your password and activate your account:\n%s\n\n"+
+		"The link expires in %s.\n", link, ttl)
+	return mailer.Default.Send(email, "You're invited to the Admin Dashboard", body)
+}
+
+// Send (or re-send) an invitation to an employee without a login account
+func InviteUser(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam) // Convert to int
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	email, err := models.GetUserEmailByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	hasAccount, err := models.UserHasAccount(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	if hasAccount {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already has a login account"})
+		return
+	}
+
+	if err := sendInvitation(id, email, callerName(c)); err != nil {
+		log.Printf("Error sending invitation to %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not send invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation sent successfully"})
+}
+
+// Accept an invitation by choosing a password, which creates the login account
+func AcceptInvitation(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	claims, err := parseClaims(input.Token, "invite")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+		return
+	}
+	jti, _ := claims["jti"].(string)
+	email, _ := claims["email"].(string)
+
+	if err := validatePassword(input.Password, email); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	hashedPassword, err := password.Hash(input.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing the password"})
+		return
+	}
+
+	email, err = models.AcceptInvitation(jti, hashedPassword, models.RoleEmployee)
+	if err != nil {
+		if err.Error() == "invalid or expired invitation" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+		} else if err.Error() == "account already exists" {
+			c.JSON(http.StatusConflict, gin.H{"error": "An account already exists for this invitation. Please log in."})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating the account"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account created successfully", "email": email})
+}
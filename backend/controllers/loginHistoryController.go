@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function to get the failure burst policy: more than LOGIN_BURST_THRESHOLD
+// (default 10) failures for one email within LOGIN_BURST_WINDOW (default 1m)
+func loginBurstPolicy() (int, time.Duration) {
+	return utils.GetIntEnv("LOGIN_BURST_THRESHOLD", 10), utils.GetDurationEnv("LOGIN_BURST_WINDOW", time.Minute)
+}
+
+// Function to get how long login events are kept (LOGIN_HISTORY_RETENTION, default 90 days)
+func loginHistoryRetention() time.Duration {
+	return utils.GetDurationEnv("LOGIN_HISTORY_RETENTION", 90*24*time.Hour)
+}
+
+// Function to remove login events older than LOGIN_HISTORY_RETENTION (run periodically)
+func DeleteOldLoginHistory() error {
+	return models.DeleteOldLoginEvents(loginHistoryRetention())
+}
+
+// Function to get the network an IP address belongs to (/24 for IPv4, /48
+// for IPv6), so a new address from the same provider is not reported as new
+func ipRange(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// Function to work out which anomalies a login attempt shows
+func loginAnomalies(email, userAgent, network string, success bool) ([]string, error) {
+	flags := []string{}
+	threshold, window := loginBurstPolicy()
+
+	if !success {
+		failures, err := models.CountRecentLoginFailures(email, window)
+		if err != nil {
+			return nil, err
+		}
+		if failures+1 >= threshold {
+			flags = append(flags, models.LoginFlagFailureBurst)
+		}
+		return flags, nil
+	}
+
+	hasHistory, knownDevice, knownRange, err := models.GetLoginFamiliarity(email, userAgent, network)
+	if err != nil {
+		return nil, err
+	}
+	// The very first login has nothing to compare with
+	if hasHistory && !knownDevice {
+		flags = append(flags, models.LoginFlagNewDevice)
+	}
+	if hasHistory && !knownRange {
+		flags = append(flags, models.LoginFlagNewIPRange)
+	}
+
+	// A burst anywhere in the lockout window makes a success suspicious
+	_, _, attemptWindow, _ := loginPolicy()
+	failures, err := models.CountRecentLoginFailures(email, attemptWindow)
+	if err != nil {
+		return nil, err
+	}
+	if failures >= threshold {
+		flags = append(flags, models.LoginFlagAfterFailureBurst)
+	}
+	return flags, nil
+}
+
+// Function to record a login attempt in the login history together with any
+// anomalies it shows. Errors are only logged so logging in never fails because of it.
+func recordLoginEvent(c *gin.Context, email, method string, success bool, failureReason string) {
+	if len(email) > 255 {
+		email = email[:255]
+	}
+	ip := c.ClientIP()
+	userAgent := requestUserAgent(c)
+	network := ipRange(ip)
+
+	flags, err := loginAnomalies(email, userAgent, network, success)
+	if err != nil {
+		log.Printf("Error checking login anomalies for %s: %v", email, err)
+		flags = []string{}
+	}
+	if len(flags) > 0 {
+		log.Printf("Suspicious login attempt for %s from %s (success: %t): %v", email, ip, success, flags)
+	}
+
+	event := models.LoginEvent{
+		Email:     email,
+		Success:   success,
+		Method:    method,
+		IPAddress: &ip,
+		UserAgent: &userAgent,
+		Flags:     flags,
+	}
+	if failureReason != "" {
+		event.FailureReason = &failureReason
+	}
+	if err := models.RecordLoginEvent(event, network); err != nil {
+		log.Printf("Error recording login event for %s: %v", email, err)
+	}
+}
+
+// Function to read the page and limit query parameters (limit at most 100).
+// Responds and returns false if they are invalid.
+func paginationParams(c *gin.Context) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page number"})
+		return 0, 0, false
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit number"})
+		return 0, 0, false
+	}
+	return page, limit, true
+}
+
+// Function to parse a from/to query parameter given as RFC 3339 or as a date (YYYY-MM-DD)
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// List the signed-in account's recent login attempts, newest first
+func GetMyLoginHistory(c *gin.Context) {
+	page, limit, ok := paginationParams(c)
+	if !ok {
+		return
+	}
+
+	filters := models.LoginEventFilters{Email: c.GetString("email")}
+	events, total, err := models.GetLoginEvents(filters, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// Search login attempts (admin only). Filters: email or user_id, success,
+// method, ip, flagged=true, flag, from and to.
+func GetLoginEvents(c *gin.Context) {
+	page, limit, ok := paginationParams(c)
+	if !ok {
+		return
+	}
+
+	filters := models.LoginEventFilters{
+		Email:     c.Query("email"),
+		Method:    c.Query("method"),
+		IPAddress: c.Query("ip"),
+		Flag:      c.Query("flag"),
+	}
+
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.Atoi(userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		email, err := models.GetUserEmailByID(id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+			return
+		}
+		filters.Email = email
+	}
+
+	if success := c.Query("success"); success != "" {
+		value, err := strconv.ParseBool(success)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid success value"})
+			return
+		}
+		filters.Success = &value
+	}
+	if flagged := c.Query("flagged"); flagged != "" {
+		value, err := strconv.ParseBool(flagged)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flagged value"})
+			return
+		}
+		filters.Flagged = value
+	}
+
+	for _, param := range []string{"from", "to"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeParam(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s value", param)})
+			return
+		}
+		if param == "from" {
+			filters.From = &t
+		} else {
+			filters.To = &t
+		}
+	}
+
+	events, total, err := models.GetLoginEvents(filters, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/utils"
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Longest progressive delay between two attempts on the same account
+const maxLoginDelay = 30 * time.Second
+
+// Function to get the lockout policy from the environment
+func loginPolicy() (accountMax, ipMax int, window, lockout time.Duration) {
+	accountMax = utils.GetIntEnv("LOGIN_MAX_ATTEMPTS", 5)
+	ipMax = utils.GetIntEnv("LOGIN_IP_MAX_ATTEMPTS", 50)
+	window = utils.GetDurationEnv("LOGIN_ATTEMPT_WINDOW", 15*time.Minute)
+	lockout = utils.GetDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	return
+}
+
+// Function to compute the delay enforced after n consecutive failures
+// (none after the first, then 1s, 2s, 4s, ... up to maxLoginDelay)
+func progressiveDelay(failures int) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	delay := time.Duration(math.Pow(2, float64(failures-2))) * time.Second
+	if delay > maxLoginDelay {
+		return maxLoginDelay
+	}
+	return delay
+}
+
+// Function to get how long a caller must wait before trying to log in again
+func loginRetryAfter(email, ip string) (time.Duration, error) {
+	account, err := models.GetLoginThrottle(models.ThrottleAccount, strings.ToLower(email))
+	if err != nil {
+		return 0, err
+	}
+	client, err := models.GetLoginThrottle(models.ThrottleIP, ip)
+	if err != nil {
+		return 0, err
+	}
+
+	wait := account.LockedFor
+	if client.LockedFor > wait {
+		wait = client.LockedFor
+	}
+	if delay := progressiveDelay(account.Failures) - account.SinceLastFailure; delay > wait {
+		wait = delay
+	}
+	return wait, nil
+}
+
+// Function to count a failed login against both the account and the IP
+func recordLoginFailure(email, ip string) {
+	accountMax, ipMax, window, lockout := loginPolicy()
+	if err := models.RecordLoginFailure(models.ThrottleAccount, strings.ToLower(email), window, accountMax, lockout); err != nil {
+		log.Printf("Error recording failed login for %s: %v", email, err)
+	}
+	if err := models.RecordLoginFailure(models.ThrottleIP, ip, window, ipMax, lockout); err != nil {
+		log.Printf("Error recording failed login from %s: %v", ip, err)
+	}
+}
+
+// Function to reset the account's failure count after a successful login
+func clearLoginFailures(email string) {
+	if err := models.ClearLoginFailures(models.ThrottleAccount, strings.ToLower(email)); err != nil {
+		log.Printf("Error clearing failed logins for %s: %v", email, err)
+	}
+}
+
+// Function to respond when the caller has to wait. Returns true if the request was rejected.
+func rejectThrottledLogin(c *gin.Context, email string) bool {
+	wait, err := loginRetryAfter(email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return true
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many login attempts. Please try again later."})
+	return true
+}
+
+// Clear the failed-login lockout of an employee's account (admin only)
+func UnlockUser(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam) // Convert to int
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	email, err := models.GetUserEmailByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if err := models.ClearLoginFailures(models.ThrottleAccount, strings.ToLower(email)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked successfully"})
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"admin-dashboard/mailer"
+	"admin-dashboard/models"
+	"admin-dashboard/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Shortest time between two sign-in links for the same account
+const magicLinkCooldown = time.Minute
+
+// Function to check whether employees can sign in with an emailed link (MAGIC_LINK_LOGIN, off by default)
+func magicLinkLoginEnabled() bool {
+	return utils.GetBoolEnv("MAGIC_LINK_LOGIN", false)
+}
+
+// Function to get how long sign-in links stay valid (MAGIC_LINK_TTL, default 15m)
+func magicLinkTTL() time.Duration {
+	return utils.GetDurationEnv("MAGIC_LINK_TTL", 15*time.Minute)
+}
+
+// Function to create a signed, single-use sign-in link for an account and email it
+func sendMagicLink(email, ip string) error {
+	jti, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return err
+	}
+
+	ttl := magicLinkTTL()
+	expiresAt := time.Now().Add(ttl)
+	token, err := signClaims(jwt.MapClaims{
+		"typ":   "magic_link",
+		"jti":   jti,
+		"email": email,
+		"exp":   expiresAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := models.CreateMagicLink(email, jti, ip, expiresAt); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/magic-link?token=%s", frontendURL(), url.QueryEscape(token))
+	body := fmt.Sprintf("Someone asked to sign in to the Admin Dashboard with this email address.\n\n"+
+		"Open this link to sign in:\n%s\n\n"+
+		"The link can be used once and expires in %s. If you did not ask to sign in, you can ignore this email.\n", link, ttl)
+	return mailer.Default.Send(email, "Your sign-in link", body)
+}
+
+// Tell the login page whether sign-in links are available
+func GetMagicLinkConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"enabled": magicLinkLoginEnabled()})
+}
+
+// Email a single-use sign-in link to the given address
+func RequestMagicLink(c *gin.Context) {
+	if !magicLinkLoginEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sign-in links are not enabled"})
+		return
+	}
+
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	// Locked-out accounts and IPs cannot get around the lockout this way
+	if rejectThrottledLogin(c, input.Email) {
+		return
+	}
+
+	// Always give the same answer, equally fast, so this cannot be used to
+	// find accounts: the link is sent in the background
+	go requestMagicLink(input.Email, c.ClientIP())
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a sign-in link has been sent"})
+}
+
+// Function to send a sign-in link if an active account uses the email and
+// none was sent within magicLinkCooldown. Runs after RequestMagicLink has
+// answered, so failures are only logged.
+func requestMagicLink(email, ip string) {
+	user, err := getUserByEmail(email)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Error looking up account for sign-in link: %v", err)
+		}
+		return
+	}
+	if !user.IsActive {
+		return
+	}
+
+	// Asking again straight away does not flood the inbox; the earlier link still works
+	recent, err := models.MagicLinkSentWithin(user.Email, magicLinkCooldown)
+	if err != nil {
+		log.Printf("Error checking recent sign-in links for %s: %v", user.Email, err)
+		return
+	}
+	if recent {
+		return
+	}
+
+	if err := sendMagicLink(user.Email, ip); err != nil {
+		log.Printf("Error sending sign-in link to %s: %v", user.Email, err)
+	}
+}
+
+// Sign in with a link from RequestMagicLink. The response is the same as for
+// a password login, including the second step for two-factor authentication.
+func MagicLinkLogin(c *gin.Context) {
+	if !magicLinkLoginEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sign-in links are not enabled"})
+		return
+	}
+
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	claims, err := parseClaims(input.Token, "magic_link")
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link"})
+		return
+	}
+	jti, _ := claims["jti"].(string)
+	email, _ := claims["email"].(string)
+	if rejectThrottledLogin(c, email) {
+		recordLoginEvent(c, email, models.LoginMethodMagicLink, false, "throttled")
+		return
+	}
+
+	accountEmail, err := models.ConsumeMagicLink(jti)
+	if err != nil {
+		if err.Error() == "invalid or expired link" {
+			// The signature is valid, so the link was already used or replaced by a newer one
+			recordLoginEvent(c, email, models.LoginMethodMagicLink, false, "link_already_used")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return
+	}
+
+	user, err := getUserByEmail(accountEmail)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link"})
+		return
+	}
+
+	continueLogin(c, user, models.LoginMethodMagicLink)
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"admin-dashboard/database"
+	"admin-dashboard/mailer"
+	"admin-dashboard/mailer/mailertest"
+	"admin-dashboard/signing"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/lib/pq"
+)
+
+// Function to connect to the database in TEST_DATABASE_URL (tests using it
+// are skipped without one) and create the schema from the migrations if it
+// is empty
+func openTestDatabase(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("connecting to TEST_DATABASE_URL: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		db.Close()
+	})
+
+	var migrated bool
+	if err := db.QueryRow("SELECT to_regclass('public.magic_links') IS NOT NULL").Scan(&migrated); err != nil {
+		t.Fatal(err)
+	}
+	if migrated {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		script, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(script)); err != nil {
+			t.Fatalf("applying %s: %v", filepath.Base(file), err)
+		}
+	}
+}
+
+func TestMagicLinkEmailRedeemsOnce(t *testing.T) {
+	openTestDatabase(t)
+	if err := signing.Rotate(); err != nil {
+		t.Fatalf("loading signing keys: %v", err)
+	}
+
+	sink, err := mailertest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.Close() })
+	previousMailer := mailer.Default
+	mailer.Default = mailer.SMTPMailer{Host: sink.Host, Port: sink.Port, From: "dashboard@example.com"}
+	t.Cleanup(func() { mailer.Default = previousMailer })
+	t.Setenv("MAGIC_LINK_LOGIN", "true")
+	t.Setenv("FRONTEND_URL", "http://localhost:3000")
+
+	// An active employee account, unique to this run
+	email := fmt.Sprintf("ml%d@example.com", time.Now().UnixNano()%1e12)
+	_, err = database.DB.Exec(`
+		WITH employee AS (
+			INSERT INTO users (first_name, last_name, gender, location, email, phone, department, role, salary, join_date, years_of_experience)
+			VALUES ('Jo', 'Link', 'Other', 'Remote', $1, $2, 'Sales', 'Agent', 1000, '2023-03-15', 1)
+			RETURNING id)
+		INSERT INTO credentials (email, password_hash, role_id, user_id)
+		SELECT $1, 'not-a-password', (SELECT id FROM roles WHERE name = 'employee'), id FROM employee`,
+		email, email[2:12])
+	if err != nil {
+		t.Fatalf("creating account: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login/magic-link", RequestMagicLink)
+	router.POST("/login/magic-link/verify", MagicLinkLogin)
+	post := func(path string, body interface{}) *httptest.ResponseRecorder {
+		encoded, _ := json.Marshal(body)
+		request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(encoded))
+		request.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	if response := post("/login/magic-link", gin.H{"email": email}); response.Code != http.StatusOK {
+		t.Fatalf("requesting a link: %d %s", response.Code, response.Body)
+	}
+
+	var message mailertest.Message
+	select {
+	case message = <-sink.Messages:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no sign-in email was sent")
+	}
+	if len(message.To) != 1 || message.To[0] != email {
+		t.Errorf("email sent to %q, want %s", message.To, email)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(message.Data))
+	if err != nil {
+		t.Fatalf("invalid email: %v", err)
+	}
+	if subject := parsed.Header.Get("Subject"); subject != "Your sign-in link" {
+		t.Errorf("Subject = %q", subject)
+	}
+	var body bytes.Buffer
+	body.ReadFrom(parsed.Body)
+	match := regexp.MustCompile(`http://localhost:3000/magic-link\?\S+`).FindString(body.String())
+	if match == "" {
+		t.Fatalf("no sign-in link in the email:\n%s", body.String())
+	}
+	link, err := url.Parse(match)
+	if err != nil {
+		t.Fatalf("invalid link %s: %v", match, err)
+	}
+	token := link.Query().Get("token")
+
+	response := post("/login/magic-link/verify", gin.H{"token": token})
+	if response.Code != http.StatusOK {
+		t.Fatalf("first use of the link: %d %s", response.Code, response.Body)
+	}
+	var login struct {
+		Token string `json:"token"`
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &login); err != nil || login.Token == "" || login.Email != email {
+		t.Errorf("unexpected login response %s", response.Body)
+	}
+
+	if response := post("/login/magic-link/verify", gin.H{"token": token}); response.Code != http.StatusUnauthorized {
+		t.Errorf("second use of the link: %d %s, want 401", response.Code, response.Body)
+	}
+}
@@ -0,0 +1,335 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/password"
+	"admin-dashboard/utils"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// How long the first login step stays valid while the user types their code
+const mfaChallengeTTL = 5 * time.Minute
+
+// Number of recovery codes handed out when TOTP is enabled
+const recoveryCodeCount = 10
+
+// Function to get the issuer name shown in authenticator apps (TOTP_ISSUER)
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Admin Dashboard"
+}
+
+// Function to create the challenge token returned by the first login step
+func generateMFAChallenge(email string) (string, error) {
+	jti, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	return signClaims(jwt.MapClaims{
+		"typ":   "mfa_challenge",
+		"email": email,
+		"jti":   jti,
+		"exp":   time.Now().Add(mfaChallengeTTL).Unix(),
+	})
+}
+
+// Function to validate a challenge token and return its email, jti and expiry
+func parseMFAChallenge(tokenString string) (string, string, time.Time, error) {
+	claims, err := parseClaims(tokenString, "mfa_challenge")
+	if err != nil {
+		return "", "", time.Time{}, errors.New("invalid challenge")
+	}
+	email, _ := claims["email"].(string)
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if email == "" || jti == "" {
+		return "", "", time.Time{}, errors.New("invalid challenge")
+	}
+	return email, jti, time.Unix(int64(exp), 0), nil
+}
+
+// Function to generate recovery codes and their hashes for storage
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// Function to check a TOTP code against an account, refusing replays
+func checkTOTPCode(settings *models.MFASettings, code string) (bool, error) {
+	step, ok := utils.ValidateTOTP(settings.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return models.UseTOTPStep(settings.CredentialID, step)
+}
+
+// Second login step: exchange the challenge token and a TOTP or recovery code for a session
+func LoginMFA(c *gin.Context) {
+	var input struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || (input.Code == "" && input.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	email, jti, expiresAt, err := parseMFAChallenge(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login challenge"})
+		return
+	}
+	if rejectThrottledLogin(c, email) {
+		recordLoginEvent(c, email, models.LoginMethodMFA, false, "throttled")
+		return
+	}
+	revoked, err := models.IsAccessTokenRevoked(jti, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if revoked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login challenge"})
+		return
+	}
+
+	user, err := getUserByEmail(email)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login challenge"})
+		return
+	}
+	settings, err := models.GetMFASettings(email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !settings.Enabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login challenge"})
+		return
+	}
+
+	var valid bool
+	if input.Code != "" {
+		valid, err = checkTOTPCode(settings, input.Code)
+	} else {
+		valid, err = models.UseRecoveryCode(settings.CredentialID, utils.HashToken(utils.NormalizeRecoveryCode(input.RecoveryCode)))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !valid {
+		recordLoginFailure(email, c.ClientIP())
+		recordLoginEvent(c, email, models.LoginMethodMFA, false, "invalid_mfa_code")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	// A challenge can only be completed once
+	if err := models.RevokeAccessToken(jti, expiresAt); err != nil {
+		log.Printf("Error revoking MFA challenge for %s: %v", email, err)
+	}
+
+	completeLogin(c, user, models.LoginMethodMFA)
+}
+
+// Get whether TOTP is enabled for the current account
+func GetMFAStatus(c *gin.Context) {
+	settings, err := models.GetMFASettings(c.GetString("email"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  settings.Enabled,
+		"recovery_codes_remaining": settings.RecoveryCodesRemaining,
+	})
+}
+
+// Start TOTP enrollment: create a secret and the otpauth URI to show as a QR code
+func EnrollTOTP(c *gin.Context) {
+	email := c.GetString("email")
+	settings, err := models.GetMFASettings(email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+		return
+	}
+	if settings.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate secret"})
+		return
+	}
+	if err := models.SetPendingTOTPSecret(settings.CredentialID, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(totpIssuer(), email, secret),
+	})
+}
+
+// Finish TOTP enrollment with a code from the app and hand out recovery codes
+func VerifyTOTP(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	settings, err := models.GetMFASettings(c.GetString("email"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+		return
+	}
+	if settings.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if settings.Secret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment first"})
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(settings.Secret, input.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate recovery codes"})
+		return
+	}
+	if err := models.EnableTOTP(settings.CredentialID, step, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error enabling two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// Replace the recovery codes of the current account (requires a current TOTP code)
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	settings, err := models.GetMFASettings(c.GetString("email"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+		return
+	}
+	if !settings.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	valid, err := checkTOTPCode(settings, input.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate recovery codes"})
+		return
+	}
+	if err := models.ReplaceRecoveryCodes(settings.CredentialID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// Turn off TOTP for the current account (requires the password and a current code)
+func DisableTOTP(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	user, err := getUserByEmail(c.GetString("email"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+		return
+	}
+	if match, _, err := password.Verify(user.PasswordHash, input.Password); err != nil || !match {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	settings, err := models.GetMFASettings(user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+		return
+	}
+	if !settings.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	valid, err := checkTOTPCode(settings, input.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	if err := models.DisableTOTP(settings.CredentialID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error disabling two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
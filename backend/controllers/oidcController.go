@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/oidc"
+	"admin-dashboard/password"
+	"admin-dashboard/utils"
+	"crypto/subtle"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How long a user has to finish signing in at the identity provider
+const oidcLoginStateTTL = 10 * time.Minute
+
+// Cookie tying a sign-on to the browser that started it: it holds the hash
+// of the state, so a callback made from another browser is refused
+const oidcStateCookie = "oidc_state"
+
+// Function to set (or, with an empty value, clear) the sign-on state cookie
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, "/auth/oidc", "", c.Request.TLS != nil, true)
+}
+
+// Function to check whether employees without a login account get one on
+// their first single sign-on (OIDC_AUTO_PROVISION, off by default)
+func oidcAutoProvisionEnabled() bool {
+	return utils.GetBoolEnv("OIDC_AUTO_PROVISION", false)
+}
+
+// Tell the login page whether single sign-on is available
+func GetOIDCConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"enabled": oidc.Default != nil})
+}
+
+// Start single sign-on: remember the state, nonce and PKCE verifier, bind the
+// state to the browser with a cookie and return the identity provider URL
+// the browser should be sent to
+func OIDCLogin(c *gin.Context) {
+	if oidc.Default == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start sign-on"})
+		return
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start sign-on"})
+		return
+	}
+	codeVerifier, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start sign-on"})
+		return
+	}
+
+	authURL, err := oidc.Default.AuthCodeURL(state, nonce, oidc.CodeChallenge(codeVerifier))
+	if err != nil {
+		log.Printf("Error contacting identity provider: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+	err = models.CreateOIDCLoginState(utils.HashToken(state), nonce, codeVerifier, time.Now().Add(oidcLoginStateTTL))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start sign-on"})
+		return
+	}
+
+	setOIDCStateCookie(c, utils.HashToken(state), int(oidcLoginStateTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// Finish single sign-on: exchange the code the provider redirected back with,
+// map the verified email to an account and issue the usual session tokens
+func OIDCCallback(c *gin.Context) {
+	if oidc.Default == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	var input struct {
+		Code  string `json:"code" binding:"required"`
+		State string `json:"state" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	// Only the browser that started the sign-on may finish it (login CSRF)
+	stateHash := utils.HashToken(input.State)
+	cookie, err := c.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(stateHash)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sign-on session expired. Please try again."})
+		return
+	}
+	setOIDCStateCookie(c, "", -1)
+
+	nonce, codeVerifier, err := models.ConsumeOIDCLoginState(stateHash)
+	if err != nil {
+		if err.Error() == "invalid or expired state" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sign-on session expired. Please try again."})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return
+	}
+
+	identity, err := oidc.Default.Exchange(input.Code, codeVerifier, nonce)
+	if err != nil {
+		log.Printf("Single sign-on failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Single sign-on failed"})
+		return
+	}
+
+	email, status, message := ssoAccountEmail(identity)
+	if status != 0 {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	user, err := getUserByEmail(email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// The identity provider is responsible for the sign-in factors here, so
+	// the local TOTP step of password logins is not asked for
+	completeLogin(c, user, models.LoginMethodSSO)
+}
+
+// Function to map a verified identity to a login account, linking it on first
+// use and creating it for known employees when auto-provisioning is on.
+// Returns the account email, or a non-zero HTTP status and an error message.
+func ssoAccountEmail(identity *oidc.Identity) (string, int, string) {
+	email, subject, err := models.GetSSOAccount(identity.Email)
+	if err == nil {
+		if subject.Valid && subject.String != identity.Subject {
+			log.Printf("Single sign-on for %s refused: account is linked to another subject", email)
+			return "", http.StatusForbidden, "This account is linked to a different identity"
+		}
+		if !subject.Valid {
+			if err := models.LinkOIDCSubject(email, identity.Subject); err != nil {
+				return "", http.StatusInternalServerError, "Database error"
+			}
+		}
+		return email, 0, ""
+	} else if err != sql.ErrNoRows {
+		return "", http.StatusInternalServerError, "Database error"
+	}
+
+	userID, userEmail, err := models.GetUserByEmailInsensitive(identity.Email)
+	if err == sql.ErrNoRows {
+		return "", http.StatusForbidden, "No employee record matches this identity"
+	} else if err != nil {
+		return "", http.StatusInternalServerError, "Database error"
+	}
+	if !oidcAutoProvisionEnabled() {
+		return "", http.StatusForbidden, "No login account exists for this employee"
+	}
+
+	// Provisioned accounts get an unusable random password; the employee can
+	// set a real one through the password reset flow if they ever need it
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", http.StatusInternalServerError, "Could not create account"
+	}
+	passwordHash, err := password.Hash(secret)
+	if err != nil {
+		return "", http.StatusInternalServerError, "Could not create account"
+	}
+	err = models.CreateSSOAccount(userID, userEmail, passwordHash, identity.Subject, models.RoleEmployee)
+	if err != nil && err.Error() != "account already exists" {
+		log.Printf("Error provisioning account for %s: %v", userEmail, err)
+		return "", http.StatusInternalServerError, "Could not create account"
+	}
+	if err != nil {
+		return "", http.StatusConflict, "Account was created by another request. Please try again."
+	}
+
+	log.Printf("Provisioned login account for %s through single sign-on", userEmail)
+	return userEmail, 0, ""
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/password"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function to check a new password against the shared password policy,
+// including the account's previous passwords if it already exists
+func validatePassword(newPassword, email string) error {
+	hashes, err := models.GetPasswordHashes(email, password.DefaultPolicy.HistorySize)
+	if err != nil {
+		return err
+	}
+	return password.DefaultPolicy.Validate(newPassword, email, hashes)
+}
+
+// Function to respond to a failed validatePassword, listing every broken rule
+func respondPasswordError(c *gin.Context, err error) {
+	var validationErr *password.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "Password does not meet the requirements",
+			"violations": validationErr.Violations,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking password"})
+}
+
+// Get the password rules so the frontend can show them before submitting
+func GetPasswordPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"policy": password.DefaultPolicy})
+}
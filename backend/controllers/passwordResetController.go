@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"admin-dashboard/database"
+	"admin-dashboard/mailer"
+	"admin-dashboard/models"
+	"admin-dashboard/password"
+	"admin-dashboard/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function to get the base URL of the frontend used in emailed links (FRONTEND_URL)
+func frontendURL() string {
+	if u := os.Getenv("FRONTEND_URL"); u != "" {
+		return u
+	}
+	return "http://localhost:3000"
+}
+
+// Shortest time between two reset links for the same account
+const passwordResetCooldown = time.Minute
+
+// Function to email a single-use password reset link if an active account
+// uses the email and none was sent within passwordResetCooldown. Runs after
+// ForgotPassword has answered, so failures are only logged.
+func sendPasswordReset(email string) {
+	var exists bool
+	err := database.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM credentials WHERE email = $1 AND is_active)", email).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking email for password reset: %v", err)
+		return
+	}
+	if !exists {
+		return
+	}
+
+	// Asking again straight away does not flood the inbox; the earlier link still works
+	recent, err := models.PasswordResetSentWithin(email, passwordResetCooldown)
+	if err != nil {
+		log.Printf("Error checking recent password resets for %s: %v", email, err)
+		return
+	}
+	if recent {
+		return
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		log.Printf("Error generating password reset token: %v", err)
+		return
+	}
+
+	ttl := utils.GetDurationEnv("PASSWORD_RESET_TTL", time.Hour)
+	if err := models.CreatePasswordResetToken(email, utils.HashToken(token), time.Now().Add(ttl)); err != nil {
+		log.Printf("Error creating password reset token for %s: %v", email, err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", frontendURL(), url.QueryEscape(token))
+	body := fmt.Sprintf("We received a request to reset your password.\n\n"+
+		"Open this link to choose a new password:\n%s\n\n"+
+		"The link can be used once and expires in %s. If you did not ask for a reset, you can ignore this email.\n", link, ttl)
+	if err := mailer.Default.Send(email, "Reset your password", body); err != nil {
+		log.Printf("Error sending password reset email to %s: %v", email, err)
+	}
+}
+
+// Send a single-use password reset link to the given email. The answer is
+// the same, and as fast, whether or not an account uses the email; the
+// link is sent in the background.
+func ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	// Locked-out accounts and IPs cannot get around the lockout this way
+	if rejectThrottledLogin(c, input.Email) {
+		return
+	}
+
+	go sendPasswordReset(input.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a reset link has been sent"})
+}
+
+// Set a new password using a token from ForgotPassword
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"newPassword" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	tokenHash := utils.HashToken(input.Token)
+	email, err := models.GetPasswordResetEmail(tokenHash)
+	if err != nil {
+		if err.Error() == "invalid or expired token" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset link"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return
+	}
+
+	if err := validatePassword(input.NewPassword, email); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	hashedPassword, err := password.Hash(input.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing the password"})
+		return
+	}
+
+	email, err = models.ResetPassword(tokenHash, hashedPassword, password.DefaultPolicy.HistorySize)
+	if err != nil {
+		if err.Error() == "invalid or expired token" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset link"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating the password"})
+		}
+		return
+	}
+
+	// Sign out every existing session now that the password has changed
+	if err := models.RevokeAllTokenFamilies(email); err != nil {
+		log.Printf("Error revoking sessions for %s: %v", email, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset successfully"})
+}
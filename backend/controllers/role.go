@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Get all roles with their permissions
+func GetRoles(c *gin.Context) {
+	roles, err := models.GetRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// Assign a role to the login account of an employee
+func UpdateUserRole(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam) // Convert to int
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var input struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	email, err := models.GetUserEmailByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	// Stop admins from accidentally locking themselves out
+	if email == c.GetString("email") && input.Role != models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot remove your own admin role"})
+		return
+	}
+
+	err = models.SetRoleByUserID(id, input.Role)
+	if err != nil {
+		if err.Error() == "role not found" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+		} else if err.Error() == "account not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User has no login account"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully"})
+}
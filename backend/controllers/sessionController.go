@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// List where the signed-in account is logged in
+func GetMySessions(c *gin.Context) {
+	sessions, err := models.GetSessions(c.GetString("email"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	currentID := c.GetString("token_family")
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// Sign out one of the signed-in account's sessions
+func RevokeMySession(c *gin.Context) {
+	err := models.RevokeSession(c.GetString("email"), c.Param("id"))
+	if err != nil {
+		if err.Error() == "session not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out session"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session signed out successfully"})
+}
+
+// Function to get the account email of an employee from the :id parameter.
+// Responds and returns false if the ID is invalid or unknown.
+func userEmailParam(c *gin.Context) (string, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return "", false
+	}
+
+	email, err := models.GetUserEmailByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return "", false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return "", false
+	}
+	return email, true
+}
+
+// List where an employee is logged in (admin only)
+func GetUserSessions(c *gin.Context) {
+	email, ok := userEmailParam(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := models.GetSessions(email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// Sign an employee out of every session (admin only)
+func RevokeUserSessions(c *gin.Context) {
+	email, ok := userEmailParam(c)
+	if !ok {
+		return
+	}
+
+	if err := models.RevokeAllTokenFamilies(email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions signed out successfully"})
+}
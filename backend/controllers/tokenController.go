@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/signing"
+	"admin-dashboard/utils"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Function to get the lifetime of access tokens (ACCESS_TOKEN_TTL, default 15m)
+func accessTokenTTL() time.Duration {
+	return utils.GetDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// Function to get the lifetime of refresh tokens (REFRESH_TOKEN_TTL, default 7 days)
+func refreshTokenTTL() time.Duration {
+	return utils.GetDurationEnv("REFRESH_TOKEN_TTL", 7*24*time.Hour)
+}
+
+// Function to sign a set of claims with the current signing key
+func signClaims(claims jwt.MapClaims) (string, error) {
+	return signing.Sign(claims)
+}
+
+// Function to verify a token signed by signClaims and check that its "typ"
+// claim matches, so one kind of token cannot be used as another
+func parseClaims(tokenString, typ string) (jwt.MapClaims, error) {
+	claims, err := signing.Parse(tokenString)
+	if err != nil || claims["typ"] != typ {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// Function to create a signed, short-lived JWT carrying the account's email
+// and role. The jti lets a single token be revoked and fam ties it to the
+// refresh token family it was issued with.
+func generateToken(email, role, familyID string) (string, error) {
+	jti, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return signClaims(jwt.MapClaims{
+		"typ":   "access",
+		"email": email,
+		"role":  role,
+		"jti":   jti,
+		"fam":   familyID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(accessTokenTTL()).Unix(),
+	})
+}
+
+// Function to get the caller's user agent, cut to fit the database columns
+func requestUserAgent(c *gin.Context) string {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	return userAgent
+}
+
+// Function to issue an access token and a refresh token in a new family,
+// which is recorded as a session with the caller's IP and user agent
+func issueTokens(c *gin.Context, email, role string) (string, string, error) {
+	familyID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	if err := models.CreateTokenFamily(familyID, email, c.ClientIP(), requestUserAgent(c)); err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	err = models.CreateRefreshToken(utils.HashToken(refreshToken), familyID, email, time.Now().Add(refreshTokenTTL()))
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := generateToken(email, role, familyID)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// Exchange a refresh token for a new access token and a rotated refresh token
+func RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	stored, err := models.GetRefreshTokenByHash(utils.HashToken(input.RefreshToken))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// A used or revoked token being presented again means it has leaked;
+	// revoke the whole family so neither party can keep using it.
+	if stored.UsedAt.Valid || stored.RevokedAt.Valid {
+		log.Printf("Refresh token reuse detected for %s, revoking family %s", stored.Email, stored.FamilyID)
+		if err := models.RevokeTokenFamily(stored.FamilyID); err != nil {
+			log.Printf("Error revoking token family %s: %v", stored.FamilyID, err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
+		return
+	}
+
+	// Re-read the role so role changes and deactivation apply on the next refresh
+	role, err := models.GetActiveRoleByEmail(stored.Email)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	newRefreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+	err = models.RotateRefreshToken(stored.ID, utils.HashToken(newRefreshToken), stored.FamilyID, stored.Email, time.Now().Add(refreshTokenTTL()))
+	if err != nil {
+		if err.Error() == "refresh token already used" {
+			if err := models.RevokeTokenFamily(stored.FamilyID); err != nil {
+				log.Printf("Error revoking token family %s: %v", stored.FamilyID, err)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return
+	}
+
+	if err := models.TouchSession(stored.FamilyID); err != nil {
+		log.Printf("Error recording activity of session %s: %v", stored.FamilyID, err)
+	}
+
+	accessToken, err := generateToken(stored.Email, role, stored.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         accessToken,
+		"refresh_token": newRefreshToken,
+		"expires_in":    int(accessTokenTTL().Seconds()),
+	})
+}
+
+// Revoke the current access token and its refresh token family
+func Logout(c *gin.Context) {
+	jti := c.GetString("jti")
+	familyID := c.GetString("token_family")
+
+	expiresAt := time.Now().Add(accessTokenTTL())
+	if exp, ok := c.Get("token_exp"); ok {
+		expiresAt = exp.(time.Time)
+	}
+
+	if err := models.RevokeAccessToken(jti, expiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking token"})
+		return
+	}
+	if familyID != "" {
+		if err := models.RevokeTokenFamily(familyID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking token"})
+			return
+		}
+	}
+	if impersonationID := c.GetInt("impersonation_id"); impersonationID != 0 {
+		if err := models.EndImpersonation(impersonationID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking token"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// Publish the public keys that verify dashboard tokens, for other services
+func GetJWKS(c *gin.Context) {
+	jwks, err := signing.JWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Signing keys unavailable"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": jwks})
+}
+
+// Replace the signing key immediately after it was exposed: tokens signed
+// with the old key are refused at once (admin only). With graceful=true they
+// stay valid for JWT_KEY_RETENTION, for rotations without an exposure.
+func RotateSigningKey(c *gin.Context) {
+	graceful := false
+	if value := c.Query("graceful"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid graceful value"})
+			return
+		}
+		graceful = parsed
+	}
+
+	rotate := signing.RotateNow
+	if graceful {
+		rotate = signing.RotateNowGraceful
+	}
+	if err := rotate(); err != nil {
+		log.Printf("Error rotating signing key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate signing key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Signing key rotated successfully"})
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"admin-dashboard/utils"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function to get how long deleted users stay in the trash (USER_TRASH_RETENTION, default 30 days)
+func userTrashRetention() time.Duration {
+	return utils.GetDurationEnv("USER_TRASH_RETENTION", 30*24*time.Hour)
+}
+
+// Function to permanently delete users that have been in the trash longer
+// than USER_TRASH_RETENTION (run periodically)
+func PurgeDeletedUsers() error {
+	purged, err := models.PurgeDeletedUsers(userTrashRetention())
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("Purged %d deleted users from the trash", purged)
+	}
+	return nil
+}
+
+// List deleted users, most recently deleted first (admin only)
+func GetTrashedUsers(c *gin.Context) {
+	page, limit, ok := paginationParams(c)
+	if !ok {
+		return
+	}
+
+	users, total, err := models.GetAllUsers((page-1)*limit, limit, models.UserFilters{Trashed: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deleted users"})
+		return
+	}
+
+	var response interface{} = users
+	if !callerHasPermission(c, models.PermUsersSalaryRead) {
+		response = withoutSalaries(users)
+	}
+
+	retention := userTrashRetention()
+	c.JSON(http.StatusOK, gin.H{
+		"users":          response,
+		"total":          total,
+		"page":           page,
+		"limit":          limit,
+		"retention_days": int(retention.Hours() / 24),
+	})
+}
+
+// Take a user out of the trash (admin only)
+func RestoreUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := models.RestoreUser(auditActor(c), id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found in the trash"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		return
+	}
+
+	log.Printf("%s restored user %d from the trash", callerName(c), id)
+	respondUser(c, http.StatusOK, user)
+}
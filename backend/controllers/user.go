@@ -2,7 +2,15 @@ package controllers
 
 import (
 	"admin-dashboard/models"
+	"admin-dashboard/utils"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"net/mail"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -10,25 +18,25 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// Get all users
-func GetUsers(c *gin.Context) {
-	page := c.DefaultQuery("page", "1")    // Default page is 1
-	limit := c.DefaultQuery("limit", "10") // Default limit is 10
-
-	pageInt, err := strconv.Atoi(page)
-	if err != nil || pageInt <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page number"})
-		return
-	}
+// Employee record without the salary, for callers lacking users:salary:read
+type userWithoutSalary struct {
+	models.Users
+	Salary *int `json:"salary,omitempty"` // shadows Users.Salary so it is left out
+}
 
-	limitInt, err := strconv.Atoi(limit)
-	if err != nil || limitInt <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit number"})
-		return
+// Function to strip salaries from a list of employee records
+func withoutSalaries(users []models.Users) []userWithoutSalary {
+	redacted := make([]userWithoutSalary, len(users))
+	for i, user := range users {
+		redacted[i] = userWithoutSalary{Users: user}
 	}
+	return redacted
+}
 
-	offset := (pageInt - 1) * limitInt
-
+// Function to read the filters of a listing of employees from the query
+// string (shared by GET /users and GET /users/export). Responds 400 or 403
+// and returns false if they are invalid.
+func userFiltersParam(c *gin.Context) (models.UserFilters, bool) {
 	// Get optional filters
 	filters := models.UserFilters{
 		FirstName: c.Query("first_name"),
@@ -84,7 +92,7 @@ func GetUsers(c *gin.Context) {
 		from, err := strconv.Atoi(salaryFrom)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid salary_from value"})
-			return
+			return filters, false
 		}
 		filters.SalaryFrom = &from
 	}
@@ -94,7 +102,7 @@ func GetUsers(c *gin.Context) {
 		to, err := strconv.Atoi(salaryTo)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid salary_to value"})
-			return
+			return filters, false
 		}
 		filters.SalaryTo = &to
 	}
@@ -104,7 +112,7 @@ func GetUsers(c *gin.Context) {
 		// Validate the date format if necessary
 		if _, err := time.Parse("2006-01-02", joinDateFrom); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid join_date_from value. Expected format: YYYY-MM-DD"})
-			return
+			return filters, false
 		}
 		filters.JoinDateFrom = joinDateFrom
 	}
@@ -114,7 +122,7 @@ func GetUsers(c *gin.Context) {
 		// Validate the date format if necessary
 		if _, err := time.Parse("2006-01-02", joinDateTo); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid join_date_to value. Expected format: YYYY-MM-DD"})
-			return
+			return filters, false
 		}
 		filters.JoinDateTo = joinDateTo
 	}
@@ -124,7 +132,7 @@ func GetUsers(c *gin.Context) {
 		from, err := strconv.Atoi(experienceFrom)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experience_from value"})
-			return
+			return filters, false
 		}
 		filters.ExperienceFrom = &from
 	}
@@ -134,89 +142,305 @@ func GetUsers(c *gin.Context) {
 		to, err := strconv.Atoi(experienceTo)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experience_to value"})
-			return
+			return filters, false
 		}
 		filters.ExperienceTo = &to
 	}
 
-	users, total, err := models.GetAllUsers(offset, limitInt, filters)
-if err != nil {
-	log.Printf("Error fetching users: %v", err) // Log the actual error
-	log.Println("Query failed:", err) // <-- ADD THIS
-	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}) // show detailed error
-	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
-	return
+	// Salary filters would reveal salaries to callers who may not see them
+	if !callerHasPermission(c, models.PermUsersSalaryRead) && (filters.SalaryFrom != nil || filters.SalaryTo != nil) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to filter by salary"})
+		return filters, false
+	}
+
+	sortKeys, ok := userSortParam(c)
+	if !ok {
+		return filters, false
+	}
+	filters.Sort = sortKeys
+
+	return filters, true
 }
 
+// Function to read the sort parameter: columns separated by commas, each
+// descending if prefixed with "-" (sort=department,-salary), or
+// sort[]=department&sort[]=-salary. Responds 400 for unknown or repeated
+// columns, 403 for salary without users:salary:read, and returns false.
+func userSortParam(c *gin.Context) ([]models.UserSort, bool) {
+	values := c.QueryArray("sort[]")
+	if len(values) == 0 {
+		if param := c.Query("sort"); param != "" {
+			values = strings.Split(param, ",")
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"users": users,
-		"total": total,
-		"page":  pageInt,
-		"limit": limitInt,
-	})
+	sortKeys := []models.UserSort{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		key := models.UserSort{Column: strings.TrimPrefix(value, "-"), Descending: strings.HasPrefix(value, "-")}
+		if !models.IsSortableUserColumn(key.Column) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown sort column: %s (allowed: %s)", key.Column, strings.Join(models.SortableUserColumns, ", "))})
+			return nil, false
+		}
+		if seen[key.Column] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sort column listed twice: " + key.Column})
+			return nil, false
+		}
+		// The order would reveal salaries to callers who may not see them
+		if key.Column == "salary" && !callerHasPermission(c, models.PermUsersSalaryRead) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to sort by salary"})
+			return nil, false
+		}
+		seen[key.Column] = true
+		sortKeys = append(sortKeys, key)
+	}
+	return sortKeys, true
 }
 
-// Create a new user
-func CreateUser(c *gin.Context) {
-	var userRequest struct {
-		First_Name          string      `json:"first_name"`
-		Last_Name           string      `json:"last_name"`
-		Gender              string      `json:"gender"`
-		Location            string      `json:"location"`
-		Email               string      `json:"email"`
-		Phone               string      `json:"phone"`
-		Department          string      `json:"department"`
-		Role                string      `json:"role"`
-		Salary              interface{} `json:"salary"`
-		Join_Date           string      `json:"join_date"`
-		Years_of_Experience interface{} `json:"years_of_experience"`
+// Get all users, a page at a time: by page number (page), or after or before
+// the record a cursor from a previous response points at (after, before).
+// count=false leaves out the total, which is slow to count for large listings.
+func GetUsers(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")    // Default page is 1
+	limit := c.DefaultQuery("limit", "10") // Default limit is 10
+
+	pageInt, err := strconv.Atoi(page)
+	if err != nil || pageInt <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page number"})
+		return
 	}
 
-	if err := c.ShouldBindJSON(&userRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil || limitInt <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit number"})
+		return
+	}
+
+	offset := (pageInt - 1) * limitInt
+
+	after, before := c.Query("after"), c.Query("before")
+	if after != "" && before != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use either after or before"})
 		return
 	}
+	usesCursor := after != "" || before != ""
+	if usesCursor && c.Query("page") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use either page or a cursor"})
+		return
+	}
+	countTotal, err := strconv.ParseBool(c.DefaultQuery("count", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count value"})
+		return
+	}
+
+	filters, ok := userFiltersParam(c)
+	if !ok {
+		return
+	}
+	canReadSalary := callerHasPermission(c, models.PermUsersSalaryRead)
 
-	// Handle salary as int or string
-	var salary int
-	switch v := userRequest.Salary.(type) {
-	case float64:
-		salary = int(v) // If it's a number
-	case string:
-		parsedSalary, err := strconv.Atoi(v) // If it's a string
+	keys := models.UserSortKeys(filters)
+	userPage := models.UserPage{Offset: offset, Limit: limitInt, CountTotal: countTotal}
+	if usesCursor {
+		userPage.Offset = 0
+		position, err := decodeUserCursor(after+before, keys)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid salary format"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor for this sort order"})
 			return
 		}
-		salary = parsedSalary
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid salary type"})
+		if after != "" {
+			userPage.After = position
+		} else {
+			userPage.Before = position
+		}
+	}
+
+	users, total, more, err := models.GetUsersPage(filters, userPage)
+	if err != nil {
+		log.Printf("Error fetching users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
 
-	// Handle years_of_experience as int or string
-	var yearsOfExperience int
-	switch v := userRequest.Years_of_Experience.(type) {
-	case float64:
-		yearsOfExperience = int(v)
-	case string:
-		parsedYears, err := strconv.Atoi(v)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid years_of_experience format"})
+	var response interface{} = users
+	if !canReadSalary {
+		response = withoutSalaries(users)
+	}
+
+	// Cursors of the neighbouring pages; more means more records in the
+	// direction the page was read
+	var nextCursor, prevCursor interface{}
+	if len(users) > 0 {
+		first, last := &users[0], &users[len(users)-1]
+		if more || before != "" {
+			nextCursor = encodeUserCursor(last, keys)
+		}
+		if (before != "" && more) || after != "" || (!usesCursor && pageInt > 1) {
+			prevCursor = encodeUserCursor(first, keys)
+		}
+	}
+
+	var links []string
+	if usesCursor {
+		if nextCursor != nil {
+			links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", pageURL(c, "after", nextCursor.(string))))
+		}
+		if prevCursor != nil {
+			links = append(links, fmt.Sprintf("<%s>; rel=\"prev\"", pageURL(c, "before", prevCursor.(string))))
+		}
+	} else {
+		if more {
+			links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", pageURL(c, "page", strconv.Itoa(pageInt+1))))
+		}
+		if pageInt > 1 {
+			links = append(links, fmt.Sprintf("<%s>; rel=\"prev\"", pageURL(c, "page", strconv.Itoa(pageInt-1))))
+		}
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+
+	body := gin.H{
+		"users":       response,
+		"limit":       limitInt,
+		"next_cursor": nextCursor,
+		"prev_cursor": prevCursor,
+	}
+	if !usesCursor {
+		body["page"] = pageInt
+	}
+	if total >= 0 {
+		body["total"] = total
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// Get one user, with related data listed in ?expand= (account, manager,
+// history). Answers 304 when If-None-Match lists the current ETag and nothing is expanded.
+func GetUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	expand, ok := expandParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := models.GetUserByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if len(expand) == 0 {
+		if header := c.GetHeader("If-None-Match"); header != "" && etagListed(header, user.ETag, true) {
+			c.Header("ETag", user.ETag)
+			c.Status(http.StatusNotModified)
 			return
 		}
-		yearsOfExperience = parsedYears
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid years_of_experience type"})
+		respondUser(c, http.StatusOK, user)
+		return
+	}
+
+	// Related data changes without the record's version, so expanded responses are never 304
+	response, err := expandedUser(c, user, expand)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch related data"})
+		return
+	}
+	c.Header("ETag", user.ETag)
+	c.JSON(http.StatusOK, response)
+}
+
+// Employee fields as sent to POST /users, or read from a row of an import
+type newUserInput struct {
+	First_Name          string      `json:"first_name"`
+	Last_Name           string      `json:"last_name"`
+	Gender              string      `json:"gender"`
+	Location            string      `json:"location"`
+	Email               string      `json:"email"`
+	Phone               string      `json:"phone"`
+	Department          string      `json:"department"`
+	Role                string      `json:"role"`
+	Salary              interface{} `json:"salary"`
+	Join_Date           string      `json:"join_date"`
+	Years_of_Experience interface{} `json:"years_of_experience"`
+}
+
+// Function to check the fields of an employee record sent to POST or PUT
+// /users, or read from a row of an import, with the checks of PATCH
+// /users/:id (validateUserField), and convert them to a record. Returns every
+// problem found; POST and PUT /users report the first.
+func parseNewUser(input newUserInput) (models.NewUser, []string) {
+	values := map[string]interface{}{
+		"first_name": input.First_Name, "last_name": input.Last_Name, "gender": input.Gender, "location": input.Location,
+		"email": input.Email, "phone": input.Phone, "department": input.Department, "role": input.Role,
+		"salary": input.Salary, "join_date": input.Join_Date, "years_of_experience": input.Years_of_Experience,
+	}
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var violations []string
+	for _, field := range fields {
+		converted, err := validateUserField(field, values[field])
+		if err != nil {
+			violations = append(violations, err.Error())
+			continue
+		}
+		values[field] = converted
+	}
+	if len(violations) > 0 {
+		return models.NewUser{}, violations
+	}
+
+	return models.NewUser{
+		First_Name: values["first_name"].(string), Last_Name: values["last_name"].(string), Gender: values["gender"].(string),
+		Location: values["location"].(string), Email: values["email"].(string), Phone: values["phone"].(string),
+		Department: values["department"].(string), Role: values["role"].(string), Salary: values["salary"].(int),
+		Join_Date: values["join_date"].(string), Years_of_Experience: values["years_of_experience"].(int),
+	}, nil
+}
+
+// Create a new user
+func CreateUser(c *gin.Context) {
+	var userRequest struct {
+		newUserInput
+		Send_Invite bool `json:"send_invite"`
+	}
+
+	if err := c.ShouldBindJSON(&userRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	id, err := models.CreateUser(userRequest.First_Name, userRequest.Last_Name, userRequest.Gender, userRequest.Location, userRequest.Email, userRequest.Phone, userRequest.Department, userRequest.Role, salary, userRequest.Join_Date, yearsOfExperience)
+	newUser, violations := parseNewUser(userRequest.newUserInput)
+	if len(violations) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": violations[0], "violations": violations})
+		return
+	}
+
+	id, err := models.CreateUser(auditActor(c), newUser.First_Name, newUser.Last_Name, newUser.Gender, newUser.Location, newUser.Email, newUser.Phone, newUser.Department, newUser.Role, newUser.Salary, newUser.Join_Date, newUser.Years_of_Experience)
 
 	if err != nil {
 		if err.Error() == "email already exists" {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot create user as the email already exists."})
+		} else if err.Error() == "email belongs to a deleted user" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A deleted user has this email. Restore them from the trash instead."})
+		} else if err.Error() == "email belongs to a login account" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A login account already uses this email."})
 		} else if  err.Error() == "phone already exists" {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot create user as the phone number already exists."})
 		} else {
@@ -225,7 +449,17 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": id})
+	// Optionally email the new employee a link to set their password
+	invitationSent := false
+	if userRequest.Send_Invite {
+		if err := sendInvitation(id, userRequest.Email, callerName(c)); err != nil {
+			log.Printf("Error sending invitation to %s: %v", userRequest.Email, err)
+		} else {
+			invitationSent = true
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": id, "invitation_sent": invitationSent})
 }
 
 // Update an existing user
@@ -236,69 +470,241 @@ func UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
-	var userRequest struct {
-		First_Name          string      `json:"first_name"`
-		Last_Name           string      `json:"last_name"`
-		Gender              string      `json:"gender"`
-		Location            string      `json:"location"`
-		Email               string      `json:"email"`
-		Phone               string      `json:"phone"`
-		Department          string      `json:"department"`
-		Role                string      `json:"role"`
-		Salary              interface{} `json:"salary"`
-		Join_Date           string      `json:"join_date"`
-		Years_of_Experience interface{} `json:"years_of_experience"`
-	}
-
+	var userRequest newUserInput
 	if err := c.ShouldBindJSON(&userRequest); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	// Handle salary as int or string
-	var salary int
-	switch v := userRequest.Salary.(type) {
-	case float64:
-		salary = int(v) // If it's a number
-	case string:
-		parsedSalary, err := strconv.Atoi(v) // If it's a string
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid salary format"})
-			return
-		}
-		salary = parsedSalary
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid salary type"})
+	updatedUser, violations := parseNewUser(userRequest)
+	if len(violations) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": violations[0], "violations": violations})
 		return
 	}
 
-	// Handle years_of_experience as int or string
-	var yearsOfExperience int
-	switch v := userRequest.Years_of_Experience.(type) {
-	case float64:
-		yearsOfExperience = int(v)
-	case string:
-		parsedYears, err := strconv.Atoi(v)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid years_of_experience format"})
-			return
+	// Refuse to overwrite changes the client has not seen
+	_, version, ok := checkUserIfMatch(c, id)
+	if !ok {
+		return
+	}
+
+	err := models.UpdateUser(auditActor(c), id, version, updatedUser.First_Name, updatedUser.Last_Name, updatedUser.Gender, updatedUser.Location, updatedUser.Email, updatedUser.Phone, updatedUser.Department, updatedUser.Role, updatedUser.Salary, updatedUser.Join_Date, updatedUser.Years_of_Experience)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil && err.Error() == "version mismatch" {
+		respondUserChangedDuringUpdate(c, id)
+		return
+	} else if err != nil && err.Error() == "account email change not allowed" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to change the email of this login account"})
+		return
+	} else if err != nil && err.Error() == "email already exists" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another user already has this email."})
+		return
+	} else if err != nil && err.Error() == "phone already exists" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another user already has this phone number."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	if user, err := models.GetUserByID(id); err == nil {
+		c.Header("ETag", user.ETag)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+}
+
+// Maximum lengths of the text columns of an employee record
+var userFieldMaxLengths = map[string]int{
+	"first_name": 100, "last_name": 100, "gender": 15, "location": 25, "email": 30,
+	"phone": 20, "department": 30, "role": 30, "join_date": 30,
+}
+
+// Function to check an employee field and convert it to its column value.
+// Numbers may be given as JSON numbers or numeric strings.
+func validateUserField(field string, value interface{}) (interface{}, error) {
+	switch field {
+	case "salary", "years_of_experience":
+		var number int
+		switch v := value.(type) {
+		case float64:
+			if v != float64(int(v)) {
+				return nil, fmt.Errorf("%s must be a whole number", field)
+			}
+			number = int(v)
+		case string:
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a whole number", field)
+			}
+			number = parsed
+		default:
+			return nil, fmt.Errorf("%s must be a whole number", field)
+		}
+		if number < 0 {
+			return nil, fmt.Errorf("%s cannot be negative", field)
+		}
+		return number, nil
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a string", field)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%s cannot be empty", field)
+	}
+	if maxLength := userFieldMaxLengths[field]; len([]rune(text)) > maxLength {
+		return nil, fmt.Errorf("%s must be at most %d characters", field, maxLength)
+	}
+	switch field {
+	case "email":
+		if _, err := mail.ParseAddress(text); err != nil || strings.ContainsAny(text, "<> ") {
+			return nil, fmt.Errorf("email is not a valid email address")
+		}
+	case "join_date":
+		if _, err := time.Parse("2006-01-02", text); err != nil {
+			return nil, fmt.Errorf("join_date must be a date (YYYY-MM-DD)")
+		}
+	}
+	return text, nil
+}
+
+// Function to apply the request body to an employee record as a JSON Patch
+// (Content-Type application/json-patch+json) or a JSON Merge Patch (any other
+// JSON type) and return the patched document
+func applyUserPatch(c *gin.Context, doc map[string]interface{}) (interface{}, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read request body")
+	}
+
+	if c.ContentType() == "application/json-patch+json" {
+		var operations []utils.PatchOperation
+		if err := json.Unmarshal(body, &operations); err != nil {
+			return nil, fmt.Errorf("body must be a JSON Patch array")
 		}
-		yearsOfExperience = parsedYears
+		return utils.ApplyJSONPatch(doc, operations)
+	}
+
+	var patch interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, fmt.Errorf("body must be a JSON object")
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("body must be a JSON object")
+	}
+	return utils.MergePatch(doc, patch), nil
+}
+
+// Partially update a user: only the fields in the patch are changed. Returns
+// the updated record.
+func PatchUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	switch c.ContentType() {
+	case "application/merge-patch+json", "application/json-patch+json", "application/json":
 	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid years_of_experience type"})
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Use application/merge-patch+json or application/json-patch+json"})
+		return
+	}
+
+	user, version, ok := checkUserIfMatch(c, id)
+	if !ok {
 		return
 	}
 
-	err := models.UpdateUser(id, userRequest.First_Name, userRequest.Last_Name, userRequest.Gender, userRequest.Location, userRequest.Email, userRequest.Phone, userRequest.Department, userRequest.Role, salary, userRequest.Join_Date, yearsOfExperience)
+	// The patch works on the record as GET /users shows it to the caller, so
+	// without users:salary:read the salary can be set but not read or tested
+	canReadSalary := callerHasPermission(c, models.PermUsersSalaryRead)
+	var original map[string]interface{}
+	encoded, _ := json.Marshal(user)
+	if err := json.Unmarshal(encoded, &original); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	delete(original, "etag")
+	if !canReadSalary {
+		delete(original, "salary")
+	}
+	doc := make(map[string]interface{}, len(original))
+	for field, value := range original {
+		doc[field] = value
+	}
+
+	result, err := applyUserPatch(c, doc)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patch: " + err.Error()})
+		return
+	}
+	patched, ok := result.(map[string]interface{})
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patch: the result must be an object"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+	changes := map[string]interface{}{}
+	var violations []string
+	for field := range original {
+		if _, ok := patched[field]; !ok {
+			violations = append(violations, fmt.Sprintf("%s cannot be removed", field))
+		}
+	}
+	for field, value := range patched {
+		if oldValue, ok := original[field]; ok && reflect.DeepEqual(oldValue, value) {
+			continue
+		}
+		if !models.IsPatchableUserField(field) {
+			if _, known := original[field]; known {
+				violations = append(violations, fmt.Sprintf("%s cannot be changed", field))
+			} else {
+				violations = append(violations, fmt.Sprintf("unknown field %s", field))
+			}
+			continue
+		}
+		converted, err := validateUserField(field, value)
+		if err != nil {
+			violations = append(violations, err.Error())
+			continue
+		}
+		changes[field] = converted
+	}
+	if len(violations) > 0 {
+		sort.Strings(violations)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user data", "violations": violations})
+		return
+	}
+
+	if len(changes) > 0 {
+		user, err = models.PatchUser(auditActor(c), id, version, changes)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		} else if err != nil {
+			if err.Error() == "version mismatch" {
+				respondUserChangedDuringUpdate(c, id)
+			} else if err.Error() == "account email change not allowed" {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to change the email of this login account"})
+			} else if err.Error() == "email already exists" {
+				c.JSON(http.StatusConflict, gin.H{"error": "Another user already has this email."})
+			} else if err.Error() == "phone already exists" {
+				c.JSON(http.StatusConflict, gin.H{"error": "Another user already has this phone number."})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+			}
+			return
+		}
+	}
+
+	respondUser(c, http.StatusOK, user)
 }
 
-// Delete a user
+// Move a user to the trash (restored with POST /users/:id/restore)
 func DeleteUser(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err1 := strconv.Atoi(idParam) // Convert to int
@@ -307,11 +713,22 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	err := models.DeleteUser(id)
-	if err != nil {
+	_, version, ok := checkUserIfMatch(c, id)
+	if !ok {
+		return
+	}
+
+	err := models.DeleteUser(auditActor(c), id, version)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil && err.Error() == "version mismatch" {
+		respondUserChangedDuringUpdate(c, id)
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "User moved to the trash"})
 }
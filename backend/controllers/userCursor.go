@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Contents of a cursor of GET /users: the sort order it was made for and the
+// sort key values of the record it points at. Encoded as base64url JSON;
+// clients treat it as opaque.
+type userCursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+// Function to describe sort keys as in the sort parameter, e.g. "department,-salary,id"
+func sortSpec(keys []models.UserSort) string {
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		terms[i] = key.Column
+		if key.Descending {
+			terms[i] = "-" + key.Column
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+// Function to make the cursor pointing at an employee record in a listing ordered by keys
+func encodeUserCursor(user *models.Users, keys []models.UserSort) string {
+	encoded, _ := json.Marshal(userCursor{Sort: sortSpec(keys), Values: models.UserSortValues(user, keys)})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// Function to read a cursor back into the sort key values of its record.
+// Cursors only fit the sort order they were made for.
+func decodeUserCursor(value string, keys []models.UserSort) ([]string, error) {
+	encoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cursor userCursor
+	if err := json.Unmarshal(encoded, &cursor); err != nil || len(cursor.Values) != len(keys) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if cursor.Sort != sortSpec(keys) {
+		return nil, fmt.Errorf("cursor for another sort order")
+	}
+	// The values go into the query, so they must parse as their column's type
+	for i, key := range keys {
+		switch key.Column {
+		case "id", "salary", "years_of_experience":
+			if _, err := strconv.ParseInt(cursor.Values[i], 10, 32); err != nil { // INTEGER columns
+				return nil, fmt.Errorf("invalid cursor")
+			}
+		case "created_at", "updated_at", "deleted_at":
+			if _, err := time.Parse(time.RFC3339Nano, cursor.Values[i]); err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+		}
+	}
+	return cursor.Values, nil
+}
+
+// Function to make the URL of another page of the current listing: the
+// request's URL with its page and cursor parameters replaced by param=value
+func pageURL(c *gin.Context, param, value string) string {
+	query := c.Request.URL.Query()
+	for _, name := range []string{"page", "after", "before"} {
+		query.Del(name)
+	}
+	query.Set(param, value)
+	return c.Request.URL.Path + "?" + query.Encode()
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"encoding/base64"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func rawCursor(json string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(json))
+}
+
+func TestUserCursorRoundTrip(t *testing.T) {
+	deletedAt := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	user := &models.Users{
+		ID: 42, First_Name: "Jo", Department: "Sales", Salary: 50000,
+		Join_Date: "2023-03-15T00:00:00Z", Years_of_Experience: 3,
+		CreatedAt: time.Date(2023, 3, 15, 9, 30, 0, 0, time.UTC), DeletedAt: &deletedAt,
+	}
+	tests := [][]models.UserSort{
+		{{Column: "id"}},
+		{{Column: "department"}, {Column: "salary", Descending: true}, {Column: "id"}},
+		{{Column: "join_date"}, {Column: "id"}},
+		{{Column: "created_at", Descending: true}, {Column: "years_of_experience"}, {Column: "id"}},
+		{{Column: "deleted_at", Descending: true}, {Column: "id"}},
+	}
+	for _, keys := range tests {
+		values, err := decodeUserCursor(encodeUserCursor(user, keys), keys)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", sortSpec(keys), err)
+			continue
+		}
+		if want := models.UserSortValues(user, keys); !reflect.DeepEqual(values, want) {
+			t.Errorf("%s: got %q, want %q", sortSpec(keys), values, want)
+		}
+	}
+
+	// The join date is kept as stored, not cut to its date
+	keys := []models.UserSort{{Column: "join_date"}, {Column: "id"}}
+	values, _ := decodeUserCursor(encodeUserCursor(user, keys), keys)
+	if values[0] != "2023-03-15T00:00:00Z" {
+		t.Errorf("join_date cursor value = %q", values[0])
+	}
+
+	// Padded cursors are accepted too
+	cursor := base64.URLEncoding.EncodeToString([]byte(`{"s":"id","v":["7"]}`))
+	if values, err := decodeUserCursor(cursor, []models.UserSort{{Column: "id"}}); err != nil || values[0] != "7" {
+		t.Errorf("padded cursor: got %q, %v", values, err)
+	}
+}
+
+func TestDecodeUserCursorErrors(t *testing.T) {
+	byID := []models.UserSort{{Column: "id"}}
+	bySalary := []models.UserSort{{Column: "salary", Descending: true}, {Column: "id"}}
+	byCreated := []models.UserSort{{Column: "created_at"}, {Column: "id"}}
+	tests := []struct {
+		name   string
+		cursor string
+		keys   []models.UserSort
+		want   string
+	}{
+		{"not base64", "!!!", byID, "invalid cursor"},
+		{"not JSON", rawCursor("id=7"), byID, "invalid cursor"},
+		{"too few values", rawCursor(`{"s":"-salary,id","v":["1"]}`), bySalary, "invalid cursor"},
+		{"too many values", rawCursor(`{"s":"id","v":["1","2"]}`), byID, "invalid cursor"},
+		{"other sort order", rawCursor(`{"s":"salary,id","v":["1","2"]}`), bySalary, "cursor for another sort order"},
+		{"ID not a number", rawCursor(`{"s":"id","v":["x"]}`), byID, "invalid cursor"},
+		{"ID past INTEGER", rawCursor(`{"s":"id","v":["2147483648"]}`), byID, "invalid cursor"},
+		{"salary not a number", rawCursor(`{"s":"-salary,id","v":["1e3","2"]}`), bySalary, "invalid cursor"},
+		{"time not RFC 3339", rawCursor(`{"s":"created_at,id","v":["2024-01-02 10:00:00","2"]}`), byCreated, "invalid cursor"},
+	}
+	for _, test := range tests {
+		_, err := decodeUserCursor(test.cursor, test.keys)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	c, _ := newTestContext("/users?department=Sales&page=2&after=abc&limit=10")
+	got, err := url.Parse(pageURL(c, "before", "xyz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{"department": {"Sales"}, "limit": {"10"}, "before": {"xyz"}}
+	if got.Path != "/users" || !reflect.DeepEqual(got.Query(), want) {
+		t.Errorf("got %s", got)
+	}
+}
+
+func newTestContext(target string) (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest("GET", target, nil)
+	return c, recorder
+}
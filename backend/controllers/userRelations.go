@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Number of audit events included by expand=history; GET /users/:id/history pages through all of them
+const expandedHistoryLength = 20
+
+// Related data GET /users/:id can include with ?expand=, by name
+var userExpansions = map[string]func(c *gin.Context, userID int) (interface{}, error){
+	"account": func(c *gin.Context, userID int) (interface{}, error) { return models.GetUserAccount(userID) },
+	"history": func(c *gin.Context, userID int) (interface{}, error) {
+		events, _, err := models.GetAuditEvents(models.AuditEventFilters{TargetID: &userID}, 0, expandedHistoryLength)
+		if err != nil {
+			return nil, err
+		}
+		return auditEventsForCaller(c, events), nil
+	},
+}
+
+// Function to read the expand parameter (expand=account,history or
+// expand[]=account&expand[]=history). Responds 400 and returns false for unknown names.
+func expandParam(c *gin.Context) ([]string, bool) {
+	values := c.QueryArray("expand[]")
+	if len(values) == 0 {
+		if param := c.Query("expand"); param != "" {
+			values = strings.Split(param, ",")
+		}
+	}
+
+	expand := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		if _, ok := userExpansions[value]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown expand value: " + value + " (allowed: account, history)"})
+			return nil, false
+		}
+		seen[value] = true
+		expand = append(expand, value)
+	}
+	return expand, true
+}
+
+// Function to build an employee record as the caller may see it with the
+// requested relations added under their names. Relations without data are null.
+func expandedUser(c *gin.Context, user *models.Users, expand []string) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(userRepresentation(c, user))
+	if err != nil {
+		return nil, err
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(encoded, &response); err != nil {
+		return nil, err
+	}
+
+	for _, name := range expand {
+		related, err := userExpansions[name](c, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		response[name] = related
+	}
+	return response, nil
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Function to get an employee record as the caller may see it (without the
+// salary unless they hold users:salary:read)
+func userRepresentation(c *gin.Context, user *models.Users) interface{} {
+	if !callerHasPermission(c, models.PermUsersSalaryRead) {
+		return userWithoutSalary{Users: *user}
+	}
+	return user
+}
+
+// Function to respond with an employee record and its ETag
+func respondUser(c *gin.Context, status int, user *models.Users) {
+	c.Header("ETag", user.ETag)
+	c.JSON(status, userRepresentation(c, user))
+}
+
+// Function to check whether an If-Match or If-None-Match header lists the
+// ETag. Weak tags ("W/...") only count when weak is true.
+func etagListed(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to check the If-Match header of a change to an employee record.
+// Returns the current record and the version the change must apply to (0 for
+// "*"). Responds and returns false if the record is unknown, the header is
+// missing (428) or the record has changed since the client read it (412).
+func checkUserIfMatch(c *gin.Context, id int) (*models.Users, int, bool) {
+	user, err := models.GetUserByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, 0, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return nil, 0, false
+	}
+
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "An If-Match header with the user's ETag is required"})
+		return nil, 0, false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return user, 0, true
+	}
+	if !etagListed(header, user.ETag, false) {
+		respondUserConflict(c, user)
+		return nil, 0, false
+	}
+	return user, user.Version, true
+}
+
+// Function to respond 412 with the record as it is now, so the client can
+// show what changed and retry with the new ETag
+func respondUserConflict(c *gin.Context, current *models.Users) {
+	c.Header("ETag", current.ETag)
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error":   "The user was changed by someone else. Review the current data and try again.",
+		"current": userRepresentation(c, current),
+	})
+}
+
+// Function to respond after a change failed because the record changed
+// between the If-Match check and the update
+func respondUserChangedDuringUpdate(c *gin.Context, id int) {
+	current, err := models.GetUserByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	respondUserConflict(c, current)
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"admin-dashboard/models"
+	"reflect"
+	"testing"
+)
+
+func validNewUserInput() newUserInput {
+	return newUserInput{
+		First_Name: "Jo", Last_Name: "Link", Gender: "Other", Location: "Remote",
+		Email: "jo@example.com", Phone: "555-0100", Department: "Sales", Role: "Agent",
+		Salary: float64(50000), Join_Date: "2023-03-15", Years_of_Experience: "3",
+	}
+}
+
+func TestParseNewUser(t *testing.T) {
+	got, violations := parseNewUser(validNewUserInput())
+	if violations != nil {
+		t.Fatalf("unexpected violations %q", violations)
+	}
+	want := models.NewUser{
+		First_Name: "Jo", Last_Name: "Link", Gender: "Other", Location: "Remote",
+		Email: "jo@example.com", Phone: "555-0100", Department: "Sales", Role: "Agent",
+		Salary: 50000, Join_Date: "2023-03-15", Years_of_Experience: 3,
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*newUserInput)
+		want   []string
+	}{
+		{"empty name", func(u *newUserInput) { u.First_Name = "  " }, []string{"first_name cannot be empty"}},
+		{"long location", func(u *newUserInput) { u.Location = "Llanfairpwllgwyngyll, Wales" }, []string{"location must be at most 25 characters"}},
+		{"invalid email", func(u *newUserInput) { u.Email = "Jo <jo@example.com>" }, []string{"email is not a valid email address"}},
+		{"invalid date", func(u *newUserInput) { u.Join_Date = "15/03/2023" }, []string{"join_date must be a date (YYYY-MM-DD)"}},
+		{"negative salary", func(u *newUserInput) { u.Salary = "-1" }, []string{"salary cannot be negative"}},
+		{"fractional salary", func(u *newUserInput) { u.Salary = float64(1000.5) }, []string{"salary must be a whole number"}},
+		{"missing salary", func(u *newUserInput) { u.Salary = nil }, []string{"salary must be a whole number"}},
+		{"several problems", func(u *newUserInput) {
+			u.Phone, u.Years_of_Experience, u.Department = "", "three", ""
+		}, []string{"department cannot be empty", "phone cannot be empty", "years_of_experience must be a whole number"}},
+	}
+	for _, test := range tests {
+		input := validNewUserInput()
+		test.change(&input)
+		if _, violations := parseNewUser(input); !reflect.DeepEqual(violations, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, violations, test.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// Mailer sends plain-text emails
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// Mailer used by the controllers, set by InitMailer
+var Default Mailer = LogMailer{}
+
+// SMTPMailer delivers mail through an SMTP server. Point it at a local sink
+// such as MailHog (host "localhost", port "1025") during development.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + headerValue(m.From),
+		"To: " + headerValue(to),
+		"Subject: " + headerValue(subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	addr := fmt.Sprintf("%s:%s", m.Host, m.Port)
+	return smtp.SendMail(addr, auth, m.From, []string{to}, []byte(msg))
+}
+
+// Function to strip line breaks so values cannot inject extra headers
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// LogMailer writes emails to the log instead of sending them (development only)
+type LogMailer struct{}
+
+func (LogMailer) Send(to, subject, body string) error {
+	log.Printf("Email to %s\nSubject: %s\n\n%s", to, subject, body)
+	return nil
+}
+
+// Function to choose the mailer from MAIL_DRIVER ("smtp" or "log", default "log")
+func InitMailer() {
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "25"
+		}
+		Default = SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+		log.Println("Sending mail through SMTP server", os.Getenv("SMTP_HOST"))
+	default:
+		Default = LogMailer{}
+		log.Println("MAIL_DRIVER is not smtp, emails will only be logged")
+	}
+}
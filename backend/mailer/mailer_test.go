@@ -0,0 +1,152 @@
+package mailer
+
+import (
+	"io"
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"admin-dashboard/mailer/mailertest"
+)
+
+func newSMTPSink(t *testing.T) *mailertest.Server {
+	t.Helper()
+	server, err := mailertest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// Function to wait for the next message the sink received
+func receive(t *testing.T, server *mailertest.Server) mailertest.Message {
+	t.Helper()
+	select {
+	case message := <-server.Messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return mailertest.Message{}
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newSMTPSink(t)
+	m := SMTPMailer{Host: server.Host, Port: server.Port, From: "dashboard@example.com"}
+
+	body := "Hello Jo,\n\n.A line starting with a dot\nBye\n"
+	if err := m.Send("jo@example.com", "Welcome to the Admin Dashboard", body); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	message := receive(t, server)
+	if message.From != "dashboard@example.com" || !reflect.DeepEqual(message.To, []string{"jo@example.com"}) {
+		t.Errorf("envelope from %q to %q", message.From, message.To)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(message.Data))
+	if err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	for name, want := range map[string]string{
+		"From":         "dashboard@example.com",
+		"To":           "jo@example.com",
+		"Subject":      "Welcome to the Admin Dashboard",
+		"Content-Type": "text/plain; charset=UTF-8",
+	} {
+		if got := parsed.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	received, _ := io.ReadAll(parsed.Body)
+	if got := strings.ReplaceAll(string(received), "\r\n", "\n"); strings.TrimRight(got, "\n") != strings.TrimRight(body, "\n") {
+		t.Errorf("body = %q, want %q", got, body)
+	}
+}
+
+func TestSMTPMailerStripsHeaderInjection(t *testing.T) {
+	server := newSMTPSink(t)
+	m := SMTPMailer{Host: server.Host, Port: server.Port, From: "dashboard@example.com"}
+
+	if err := m.Send("jo@example.com", "Hi\r\nBcc: evil@example.com\nX-Injected: yes", "Body\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	message := receive(t, server)
+	parsed, err := mail.ReadMessage(strings.NewReader(message.Data))
+	if err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	if got := parsed.Header.Get("Subject"); got != "HiBcc: evil@example.comX-Injected: yes" {
+		t.Errorf("Subject = %q", got)
+	}
+	for _, name := range []string{"Bcc", "X-Injected"} {
+		if got := parsed.Header.Get(name); got != "" {
+			t.Errorf("injected %s header %q", name, got)
+		}
+	}
+	if !reflect.DeepEqual(message.To, []string{"jo@example.com"}) {
+		t.Errorf("envelope to %q", message.To)
+	}
+
+	// Addresses with line breaks are refused rather than sent
+	if err := m.Send("jo@example.com\r\nRCPT TO:<evil@example.com>", "Hi", "Body\n"); err == nil {
+		t.Error("recipient with a line break was accepted")
+	}
+	select {
+	case message := <-server.Messages:
+		t.Errorf("message sent to %q", message.To)
+	default:
+	}
+}
+
+func TestHeaderValue(t *testing.T) {
+	for value, want := range map[string]string{
+		"plain":             "plain",
+		"a\r\nb":            "ab",
+		"a\nb\rc":           "abc",
+		"Ünïcode subject ✓": "Ünïcode subject ✓",
+	} {
+		if got := headerValue(value); got != want {
+			t.Errorf("headerValue(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestInitMailer(t *testing.T) {
+	previous := Default
+	t.Cleanup(func() { Default = previous })
+
+	for _, driver := range []string{"", "log", "sendmail"} {
+		t.Setenv("MAIL_DRIVER", driver)
+		InitMailer()
+		if _, ok := Default.(LogMailer); !ok {
+			t.Errorf("MAIL_DRIVER=%q: got %T, want LogMailer", driver, Default)
+		}
+	}
+
+	t.Setenv("MAIL_DRIVER", "smtp")
+	t.Setenv("SMTP_HOST", "mail.example.com")
+	t.Setenv("SMTP_PORT", "")
+	t.Setenv("SMTP_USERNAME", "user")
+	t.Setenv("SMTP_PASSWORD", "secret")
+	t.Setenv("MAIL_FROM", "dashboard@example.com")
+	InitMailer()
+	want := SMTPMailer{Host: "mail.example.com", Port: "25", Username: "user", Password: "secret", From: "dashboard@example.com"}
+	if Default != Mailer(want) {
+		t.Errorf("got %#v, want %#v", Default, want)
+	}
+
+	t.Setenv("SMTP_PORT", "1025")
+	InitMailer()
+	if m, ok := Default.(SMTPMailer); !ok || m.Port != "1025" {
+		t.Errorf("SMTP_PORT=1025: got %#v", Default)
+	}
+}
+
+func TestLogMailerSend(t *testing.T) {
+	if err := (LogMailer{}).Send("jo@example.com", "Subject", "Body"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+// Package mailertest provides an SMTP server for tests that accepts every
+// message and hands it to the test instead of delivering it.
+package mailertest
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message is one email as received by the server
+type Message struct {
+	From string   // envelope sender (MAIL FROM)
+	To   []string // envelope recipients (RCPT TO)
+	Data string   // headers and body, with the SMTP dot-stuffing removed
+}
+
+// Server is an SMTP sink listening on a local port. It speaks just enough
+// SMTP (EHLO/HELO, MAIL, RCPT, DATA, RSET, NOOP, QUIT) for net/smtp.
+type Server struct {
+	Host     string
+	Port     string
+	Messages chan Message // every accepted message, in order
+
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+// Function to start a server on a free port of 127.0.0.1. Close stops it.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	s := &Server{Host: host, Port: port, Messages: make(chan Message, 16), listener: listener}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Function to stop accepting connections and wait for open ones to finish
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(textproto.NewConn(conn))
+		}()
+	}
+}
+
+// Function to run one SMTP session
+func (s *Server) handle(conn *textproto.Conn) {
+	reply := func(line string) bool {
+		return conn.PrintfLine("%s", line) == nil
+	}
+	if !reply("220 localhost test SMTP server") {
+		return
+	}
+
+	var message Message
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			message = Message{}
+			reply("250 localhost")
+		case "MAIL":
+			message = Message{From: envelopeAddress(arg, "FROM:")}
+			reply("250 OK")
+		case "RCPT":
+			message.To = append(message.To, envelopeAddress(arg, "TO:"))
+			reply("250 OK")
+		case "DATA":
+			if message.From == "" || len(message.To) == 0 {
+				reply("503 MAIL and RCPT first")
+				continue
+			}
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(bufio.NewReader(conn.DotReader()))
+			if err != nil {
+				return
+			}
+			message.Data = string(data)
+			s.Messages <- message
+			message = Message{}
+			reply("250 OK")
+		case "RSET":
+			message = Message{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// Function to read the address of "FROM:<a@b> BODY=8BITMIME" or "TO:<a@b>"
+func envelopeAddress(arg, prefix string) string {
+	if len(arg) >= len(prefix) && strings.EqualFold(arg[:len(prefix)], prefix) {
+		arg = arg[len(prefix):]
+	}
+	arg, _, _ = strings.Cut(strings.TrimSpace(arg), " ")
+	return strings.Trim(arg, "<>")
+}
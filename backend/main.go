@@ -4,18 +4,70 @@ import (
 	"admin-dashboard/database"
 	"admin-dashboard/routes"
 	"admin-dashboard/controllers"
+	"admin-dashboard/mailer"
+	"admin-dashboard/models"
+	"admin-dashboard/oidc"
+	"admin-dashboard/password"
+	"admin-dashboard/signing"
+	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
 )
 
 func main() {
+	// Environment variables from the .env file, loaded before any setup reads them
+	if err := godotenv.Load(); err != nil {
+		log.Fatalf("Error loading .env file")
+	}
+
 	// Database setup
 	database.InitDB()
-	
+
+	// JWT signing keys setup (creates the first key on a fresh database)
+	signing.Init()
+
+	// Mailer setup
+	mailer.InitMailer()
+
+	// Password policy and hashing setup (used by RegisterAdmin below)
+	password.InitPolicy()
+	password.InitHasher()
+
+	// Single sign-on setup (disabled unless OIDC_ISSUER_URL is set)
+	oidc.InitProvider()
 
 	// Register the admin user
 	controllers.RegisterAdmin()
 
+	// Periodically rotate signing keys and remove expired tokens and revocation entries
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := signing.Rotate(); err != nil {
+				log.Println("Error rotating signing keys:", err)
+			}
+			if err := models.DeleteExpiredTokens(); err != nil {
+				log.Println("Error deleting expired tokens:", err)
+			}
+			if err := models.DeleteExpiredPasswordResetTokens(); err != nil {
+				log.Println("Error deleting expired password reset tokens:", err)
+			}
+			if err := models.DeleteExpiredOIDCLoginStates(); err != nil {
+				log.Println("Error deleting expired single sign-on states:", err)
+			}
+			if err := models.DeleteExpiredMagicLinks(); err != nil {
+				log.Println("Error deleting expired sign-in links:", err)
+			}
+			if err := controllers.DeleteOldLoginHistory(); err != nil {
+				log.Println("Error deleting old login history:", err)
+			}
+			if err := controllers.PurgeDeletedUsers(); err != nil {
+				log.Println("Error purging deleted users:", err)
+			}
+		}
+	}()
+
 	router := gin.Default()
 	routes.SetupRoutes(router)
 	router.Run(":8080")
@@ -1,80 +1,182 @@
 package middleware
 
 import (
+	"admin-dashboard/models"
+	"admin-dashboard/signing"
+	"admin-dashboard/utils"
+	"database/sql"
 	"log"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv" // Import godotenv package
 )
 
-// Load environment variables from the .env file
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalf("Error loading .env file")
-	}
-}
-
 // Middleware to protect routes and validate JWT token
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get the authorization header (token)
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "No authorization header"})
+		if status, message := authenticate(c); status != 0 {
+			c.JSON(status, gin.H{"error": message})
 			c.Abort()
 			return
 		}
 
-		// Retrieve the secret key from the environment variables
-		SecretKey := []byte(os.Getenv("SECRET_KEY"))
-		if SecretKey == nil || len(SecretKey) == 0 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Secret key not found"})
-			return
-		}
+		// If token is valid, proceed to the next middleware/handler
+		c.Next()
+		recordImpersonatedRequest(c)
+	}
+}
 
-		// Extract token from the header (bearer token)
-		// Check if the token has correct format
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
-			return
+// Middleware for public routes that behave differently for signed-in callers.
+// A missing or invalid token is not an error; the context is simply left empty.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			authenticate(c)
 		}
+		c.Next()
+		recordImpersonatedRequest(c)
+	}
+}
 
-		tokenString := parts[1] // <-- Add this line to assign the token string
+// Function to validate the bearer token and fill the context with its claims.
+// Returns a non-zero HTTP status and an error message if the token is not accepted.
+func authenticate(c *gin.Context) (int, string) {
+	// Get the authorization header (token)
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return http.StatusUnauthorized, "No authorization header"
+	}
 
-		// Parse and validate the JWT token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.NewValidationError("Invalid signing method", jwt.ValidationErrorSignatureInvalid)
-			}
-			return SecretKey, nil
-		})
+	// Extract token from the header (bearer token)
+	// Check if the token has correct format
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return http.StatusUnauthorized, "Invalid authorization header format"
+	}
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
-			return
+	tokenString := parts[1]
+
+	// Long-lived API tokens are opaque and looked up in the database
+	if strings.HasPrefix(tokenString, models.APITokenPrefix) {
+		return authenticateAPIToken(c, tokenString)
+	}
+
+	// Parse and validate the JWT token against the published signing keys
+	claims, err := signing.Parse(tokenString)
+	if err != nil {
+		return http.StatusUnauthorized, "Invalid or expired token"
+	}
+
+	// Extract the email from the token claims
+	if claims["email"] == nil {
+		return http.StatusUnauthorized, "No email found in token"
+	}
+	email, _ := claims["email"].(string)
+
+	// Only access tokens open protected routes (not e.g. MFA challenges)
+	if claims["typ"] != "access" {
+		return http.StatusUnauthorized, "Invalid or expired token"
+	}
+
+	// Reject tokens that were revoked on logout or through their refresh family
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return http.StatusUnauthorized, "Invalid or expired token"
+	}
+	familyID, _ := claims["fam"].(string)
+	revoked, err := models.IsAccessTokenRevoked(jti, familyID)
+	if err != nil {
+		return http.StatusInternalServerError, "Error checking token"
+	}
+	if revoked {
+		return http.StatusUnauthorized, "Token has been revoked"
+	}
+	if familyID != "" {
+		if err := models.TouchSession(familyID); err != nil {
+			log.Printf("Error recording activity of session %s: %v", familyID, err)
 		}
+	}
 
-		// Extract the email from the token claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || claims["email"] == nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "No email found in token"})
-			c.Abort()
-			return
+	// Impersonation tokens also name the admin acting as the account ("act")
+	// and stop working as soon as the impersonation is ended
+	impersonator, impersonationID := "", 0
+	if act, ok := claims["act"].(map[string]interface{}); ok {
+		impersonator, _ = act["sub"].(string)
+		id, _ := claims["imp"].(float64)
+		impersonationID = int(id)
+		if impersonator == "" || impersonationID == 0 {
+			return http.StatusUnauthorized, "Invalid or expired token"
+		}
+		active, err := models.IsImpersonationActive(impersonationID)
+		if err != nil {
+			return http.StatusInternalServerError, "Error checking token"
 		}
+		if !active {
+			return http.StatusUnauthorized, "Impersonation has ended"
+		}
+	}
 
-		// Set the user email into the context for later use
-		c.Set("email", claims["email"].(string))
+	// Set the user email into the context for later use
+	c.Set("email", email)
+	if impersonationID != 0 {
+		c.Set("impersonator", impersonator)
+		c.Set("impersonation_id", impersonationID)
+	}
 
-		// If token is valid, proceed to the next middleware/handler
-		c.Next()
+	// Set the role into the context for RequireRole/RequirePermission
+	if role, ok := claims["role"].(string); ok {
+		c.Set("role", role)
+	}
+
+	// Keep token identifiers around for /logout
+	c.Set("jti", jti)
+	c.Set("token_family", familyID)
+	if exp, ok := claims["exp"].(float64); ok {
+		c.Set("token_exp", time.Unix(int64(exp), 0))
+	}
+
+	return 0, ""
+}
+
+// Function to validate a personal or service account API token and fill the
+// context with its owner and scopes
+func authenticateAPIToken(c *gin.Context, tokenString string) (int, string) {
+	owner, err := models.GetAPITokenOwner(utils.HashToken(tokenString))
+	if err == sql.ErrNoRows {
+		return http.StatusUnauthorized, "Invalid or expired token"
+	} else if err != nil {
+		return http.StatusInternalServerError, "Error checking token"
+	}
+
+	if err := models.TouchAPIToken(owner.TokenID, c.ClientIP()); err != nil {
+		log.Printf("Error recording use of API token %d: %v", owner.TokenID, err)
+	}
+
+	if owner.ServiceAccount != "" {
+		c.Set("service_account", owner.ServiceAccount)
+	} else {
+		c.Set("email", owner.Email)
+		c.Set("role", owner.Role)
+	}
+	c.Set("api_token_id", owner.TokenID)
+	c.Set("scopes", owner.Scopes)
+
+	return 0, ""
+}
+
+// Function to add a request made with an impersonation token to the audit trail
+func recordImpersonatedRequest(c *gin.Context) {
+	id := c.GetInt("impersonation_id")
+	if id == 0 {
+		return
+	}
+	path := c.Request.URL.Path
+	if len(path) > 255 {
+		path = path[:255]
+	}
+	if err := models.RecordImpersonationAction(id, c.Request.Method, path, c.Writer.Status(), c.ClientIP()); err != nil {
+		log.Printf("Error recording impersonated request %s %s: %v", c.Request.Method, path, err)
 	}
 }
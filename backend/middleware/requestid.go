@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"admin-dashboard/utils"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Longest X-Request-ID accepted from a client or proxy
+const maxRequestIDLength = 64
+
+// Function to check that a client-supplied request ID is short and made of
+// letters, digits, "-", "_" and "." only, so it is safe to store and log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware to give every request an ID ("request_id" in the context), taken
+// from the X-Request-ID header when a proxy set a valid one and generated
+// otherwise. It is sent back in X-Request-ID and recorded with audit events.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !validRequestID(id) {
+			generated, err := utils.GenerateRandomToken(16)
+			if err != nil {
+				log.Printf("Error generating request ID: %v", err)
+			}
+			id = generated
+		}
+
+		c.Set("request_id", id)
+		if id != "" {
+			c.Header("X-Request-ID", id)
+		}
+		c.Next()
+	}
+}
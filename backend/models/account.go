@@ -0,0 +1,33 @@
+package models
+
+import (
+	"admin-dashboard/database"
+)
+
+// Function to activate or deactivate the login account linked to an employee.
+// Deactivating revokes every session at once. Returns the account email.
+func SetAccountActive(userID int, active bool) (string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRow(`
+		UPDATE credentials
+		SET is_active = $1, deactivated_at = CASE WHEN $1 THEN NULL ELSE NOW() END
+		WHERE user_id = $2
+		RETURNING email`, active, userID).Scan(&email)
+	if err != nil {
+		return "", err
+	}
+
+	if !active {
+		if err := revokeAllTokenFamiliesTx(tx, email); err != nil {
+			return "", err
+		}
+	}
+
+	return email, tx.Commit()
+}
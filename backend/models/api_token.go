@@ -0,0 +1,224 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Prefix of every API token, so they can be told apart from JWTs (and found by secret scanners)
+const APITokenPrefix = "adp_"
+
+type APIToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // first characters of the token, to recognise it
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// The identity behind a valid API token. Personal tokens carry the owner's
+// email and current role; service account tokens carry the account name.
+type APITokenOwner struct {
+	TokenID        int
+	Scopes         []string
+	Email          string
+	Role           string
+	ServiceAccount string
+}
+
+type ServiceAccount struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DisabledAt  *time.Time `json:"disabled_at"`
+}
+
+// Function to store a hashed personal token for the login account with the given email
+func CreatePersonalAPIToken(email, name, tokenHash, prefix string, scopes []string, createdBy string, expiresAt *time.Time) (int, error) {
+	var id int
+	err := database.DB.QueryRow(`
+		INSERT INTO api_tokens (name, token_hash, token_prefix, credential_id, scopes, created_by, expires_at, created_at)
+		SELECT $1, $2, $3, id, $5, $6, $7, NOW() FROM credentials WHERE email = $4
+		RETURNING id`, name, tokenHash, prefix, email, pq.Array(scopes), createdBy, expiresAt).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("account not found")
+	}
+	return id, err
+}
+
+// Function to store a hashed token for an enabled service account
+func CreateServiceAccountToken(serviceAccountID int, name, tokenHash, prefix string, scopes []string, createdBy string, expiresAt *time.Time) (int, error) {
+	var id int
+	err := database.DB.QueryRow(`
+		INSERT INTO api_tokens (name, token_hash, token_prefix, service_account_id, scopes, created_by, expires_at, created_at)
+		SELECT $1, $2, $3, id, $5, $6, $7, NOW() FROM service_accounts WHERE id = $4 AND disabled_at IS NULL
+		RETURNING id`, name, tokenHash, prefix, serviceAccountID, pq.Array(scopes), createdBy, expiresAt).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("service account not found")
+	}
+	return id, err
+}
+
+// Function to list the tokens of the login account with the given email, newest first
+func GetPersonalAPITokens(email string) ([]APIToken, error) {
+	return getAPITokens("t.credential_id = (SELECT id FROM credentials WHERE email = $1)", email)
+}
+
+// Function to list the tokens of a service account, newest first
+func GetServiceAccountTokens(serviceAccountID int) ([]APIToken, error) {
+	return getAPITokens("t.service_account_id = $1", serviceAccountID)
+}
+
+func getAPITokens(where string, owner interface{}) ([]APIToken, error) {
+	rows, err := database.DB.Query(`
+		SELECT t.id, t.name, t.token_prefix, t.scopes, t.created_by, t.created_at,
+		       t.expires_at, t.last_used_at, t.last_used_ip, t.revoked_at
+		FROM api_tokens t WHERE `+where+`
+		ORDER BY t.created_at DESC, t.id DESC`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var token APIToken
+		err := rows.Scan(&token.ID, &token.Name, &token.Prefix, pq.Array(&token.Scopes), &token.CreatedBy, &token.CreatedAt,
+			&token.ExpiresAt, &token.LastUsedAt, &token.LastUsedIP, &token.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// Function to revoke a token of the login account with the given email
+func RevokePersonalAPIToken(email string, tokenID int) error {
+	return revokeAPIToken("credential_id = (SELECT id FROM credentials WHERE email = $2)", tokenID, email)
+}
+
+// Function to revoke a token of a service account
+func RevokeServiceAccountToken(serviceAccountID, tokenID int) error {
+	return revokeAPIToken("service_account_id = $2", tokenID, serviceAccountID)
+}
+
+func revokeAPIToken(where string, tokenID int, owner interface{}) error {
+	result, err := database.DB.Exec(`
+		UPDATE api_tokens SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1 AND `+where, tokenID, owner)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("token not found")
+	}
+	return nil
+}
+
+// Function to resolve an API token hash to its owner. Revoked and expired
+// tokens, and tokens of deactivated accounts or disabled service accounts,
+// are reported as sql.ErrNoRows.
+func GetAPITokenOwner(tokenHash string) (*APITokenOwner, error) {
+	var owner APITokenOwner
+	var email, role, serviceAccount sql.NullString
+	err := database.DB.QueryRow(`
+		SELECT t.id, t.scopes, c.email, r.name, s.name
+		FROM api_tokens t
+		LEFT JOIN credentials c ON c.id = t.credential_id
+		LEFT JOIN roles r ON r.id = c.role_id
+		LEFT JOIN service_accounts s ON s.id = t.service_account_id
+		WHERE t.token_hash = $1
+		  AND t.revoked_at IS NULL
+		  AND (t.expires_at IS NULL OR t.expires_at > NOW())
+		  AND (t.credential_id IS NULL OR c.is_active)
+		  AND (t.service_account_id IS NULL OR s.disabled_at IS NULL)`, tokenHash).Scan(
+		&owner.TokenID, pq.Array(&owner.Scopes), &email, &role, &serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+	owner.Email = email.String
+	owner.Role = role.String
+	owner.ServiceAccount = serviceAccount.String
+	return &owner, nil
+}
+
+// Function to record when and from where a token was last used. Writes at
+// most once a minute per token so busy scripts do not hammer the table.
+func TouchAPIToken(tokenID int, ip string) error {
+	_, err := database.DB.Exec(`
+		UPDATE api_tokens SET last_used_at = NOW(), last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute' OR last_used_ip IS DISTINCT FROM $2)`,
+		tokenID, ip)
+	return err
+}
+
+// Function to create a service account. Returns "service account already exists" if the name is taken.
+func CreateServiceAccount(name, description, createdBy string) (int, error) {
+	var id int
+	err := database.DB.QueryRow(`
+		INSERT INTO service_accounts (name, description, created_by, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id`, name, description, createdBy).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("service account already exists")
+	}
+	return id, err
+}
+
+// Function to list every service account
+func GetServiceAccounts() ([]ServiceAccount, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, name, description, created_by, created_at, disabled_at
+		FROM service_accounts ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []ServiceAccount{}
+	for rows.Next() {
+		var account ServiceAccount
+		err := rows.Scan(&account.ID, &account.Name, &account.Description, &account.CreatedBy, &account.CreatedAt, &account.DisabledAt)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// Function to disable a service account and revoke all of its tokens
+func DisableServiceAccount(id int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE service_accounts SET disabled_at = COALESCE(disabled_at, NOW()) WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("service account not found")
+	}
+	_, err = tx.Exec("UPDATE api_tokens SET revoked_at = NOW() WHERE service_account_id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Actions recorded in audit_events
+const (
+	AuditUserCreated  = "user.created"
+	AuditUserUpdated  = "user.updated"
+	AuditUserDeleted  = "user.deleted"
+	AuditUserRestored = "user.restored"
+	AuditUserPurged   = "user.purged"
+)
+
+// AuditActor is who made a change and from which request
+type AuditActor struct {
+	Actor        string // account email, or service account name
+	Impersonator string // admin acting as Actor, if impersonating
+	RequestID    string
+	IPAddress    string
+	Role         string // role of Actor; with ManagesRoles, decides whose login accounts it may change
+	ManagesRoles bool
+}
+
+// Function to check whether the actor may change a login account with the
+// given role, e.g. its email: only with roles:manage or a higher-ranking role
+func (a AuditActor) MayManageAccount(role string) bool {
+	return a.ManagesRoles || RoleOutranks(a.Role, role)
+}
+
+// SystemActor is recorded for changes made by background jobs
+var SystemActor = AuditActor{Actor: "system"}
+
+// FieldChange is the value of one field before and after a change (null
+// before a record existed, and both null once the record was purged)
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEvent is one recorded change to an employee record
+type AuditEvent struct {
+	ID           int64                  `json:"id"`
+	Actor        string                 `json:"actor"`
+	Impersonator *string                `json:"impersonator"`
+	Action       string                 `json:"action"`
+	TargetID     int                    `json:"target_id"`
+	Changes      map[string]FieldChange `json:"changes"`
+	RequestID    *string                `json:"request_id"`
+	IPAddress    *string                `json:"ip_address"`
+	CreatedAt    time.Time              `json:"created_at"`
+	RedactedAt   *time.Time             `json:"redacted_at,omitempty"` // values cleared when the record was purged
+}
+
+// AuditEventFilters narrows down GetAuditEvents; zero values do not filter
+type AuditEventFilters struct {
+	Actor     string
+	Actions   []string
+	TargetID  *int
+	Field     string // only events that changed this field
+	RequestID string
+	From      *time.Time
+	To        *time.Time
+}
+
+// Function to get the audited fields of an employee record by their JSON
+// names; nil gives no fields
+func auditedUserFields(user *Users) map[string]interface{} {
+	if user == nil {
+		return map[string]interface{}{}
+	}
+	fields := map[string]interface{}{
+		"first_name":          user.First_Name,
+		"last_name":           user.Last_Name,
+		"gender":              user.Gender,
+		"location":            user.Location,
+		"email":               user.Email,
+		"phone":               user.Phone,
+		"department":          user.Department,
+		"role":                user.Role,
+		"salary":              user.Salary,
+		"join_date":           user.Join_Date,
+		"years_of_experience": user.Years_of_Experience,
+		"deleted_at":          nil,
+	}
+	if user.DeletedAt != nil {
+		fields["deleted_at"] = *user.DeletedAt
+	}
+	return fields
+}
+
+// Function to list the fields that differ between two versions of an
+// employee record. before is nil for a new record.
+func diffUser(before, after *Users) map[string]FieldChange {
+	old, updated := auditedUserFields(before), auditedUserFields(after)
+	changes := map[string]FieldChange{}
+	for _, fields := range []map[string]interface{}{old, updated} {
+		for field := range fields {
+			if !sameAuditValue(old[field], updated[field]) {
+				changes[field] = FieldChange{Before: old[field], After: updated[field]}
+			}
+		}
+	}
+	return changes
+}
+
+// Function to compare two field values; times are compared as instants since
+// they may come back from the database with different locations
+func sameAuditValue(a, b interface{}) bool {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	return a == b
+}
+
+// Function to record a change to an employee record inside the transaction
+// that makes it, so the change and its audit event are stored together or not at all
+func recordAuditEventTx(tx *sql.Tx, actor AuditActor, action string, targetID int, changes map[string]FieldChange) error {
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO audit_events (actor, impersonator, action, target_id, changes, request_id, ip_address, created_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NOW())`,
+		actor.Actor, actor.Impersonator, action, targetID, string(encoded), actor.RequestID, actor.IPAddress)
+	return err
+}
+
+// Function to clear the values of the recorded changes to purged records,
+// keeping which fields changed, when and by whom. This is the only change
+// migrations/020_audit_events_redaction.sql allows to audit_events.
+func redactAuditEventsTx(tx *sql.Tx, targetIDs []int) error {
+	if _, err := tx.Exec("SET LOCAL audit_events.redact = 'on'"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		UPDATE audit_events SET
+			changes = COALESCE((SELECT jsonb_object_agg(field, '{}'::jsonb) FROM jsonb_object_keys(changes) AS field), '{}'::jsonb),
+			redacted_at = NOW()
+		WHERE target_id = ANY($1) AND redacted_at IS NULL`, pq.Array(targetIDs))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("SET LOCAL audit_events.redact = 'off'")
+	return err
+}
+
+// Function to search audit events, newest first
+func GetAuditEvents(filters AuditEventFilters, offset, limit int) ([]AuditEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	argIndex := 1
+
+	if filters.Actor != "" {
+		conditions = append(conditions, fmt.Sprintf("(LOWER(actor) = LOWER($%d) OR LOWER(impersonator) = LOWER($%d))", argIndex, argIndex))
+		args = append(args, filters.Actor)
+		argIndex++
+	}
+	if len(filters.Actions) > 0 {
+		conditions = append(conditions, fmt.Sprintf("action = ANY($%d)", argIndex))
+		args = append(args, pq.Array(filters.Actions))
+		argIndex++
+	}
+	if filters.TargetID != nil {
+		conditions = append(conditions, fmt.Sprintf("target_id = $%d", argIndex))
+		args = append(args, *filters.TargetID)
+		argIndex++
+	}
+	if filters.Field != "" {
+		conditions = append(conditions, fmt.Sprintf("changes ? $%d", argIndex))
+		args = append(args, filters.Field)
+		argIndex++
+	}
+	if filters.RequestID != "" {
+		conditions = append(conditions, fmt.Sprintf("request_id = $%d", argIndex))
+		args = append(args, filters.RequestID)
+		argIndex++
+	}
+	if filters.From != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argIndex))
+		args = append(args, *filters.From)
+		argIndex++
+	}
+	if filters.To != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", argIndex))
+		args = append(args, *filters.To)
+		argIndex++
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM audit_events "+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, actor, impersonator, action, target_id, changes, request_id, ip_address, created_at, redacted_at
+		FROM audit_events %s
+		ORDER BY created_at DESC, id DESC
+		OFFSET $%d LIMIT $%d`, whereClause, argIndex, argIndex+1)
+	rows, err := database.DB.Query(query, append(args, offset, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		var changes []byte
+		err := rows.Scan(&event.ID, &event.Actor, &event.Impersonator, &event.Action, &event.TargetID, &changes,
+			&event.RequestID, &event.IPAddress, &event.CreatedAt, &event.RedactedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal(changes, &event.Changes); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, total, rows.Err()
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type Impersonation struct {
+	ID            int                   `json:"id"`
+	ActorEmail    string                `json:"actor_email"`
+	SubjectEmail  string                `json:"subject_email"`
+	SubjectUserID *int                  `json:"subject_user_id"`
+	Reason        string                `json:"reason"`
+	IPAddress     *string               `json:"ip_address"`
+	StartedAt     time.Time             `json:"started_at"`
+	ExpiresAt     time.Time             `json:"expires_at"`
+	EndedAt       *time.Time            `json:"ended_at"`
+	ActionCount   int                   `json:"action_count"`
+	Actions       []ImpersonationAction `json:"actions,omitempty"`
+}
+
+type ImpersonationAction struct {
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	IPAddress *string   `json:"ip_address"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Function to record the start of an impersonation. Returns its ID.
+func CreateImpersonation(actorEmail, subjectEmail string, subjectUserID int, reason, jti, ipAddress string, expiresAt time.Time) (int, error) {
+	var id int
+	err := database.DB.QueryRow(`
+		INSERT INTO impersonation_sessions (actor_email, subject_email, subject_user_id, reason, jti, ip_address, started_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), $7)
+		RETURNING id`, actorEmail, subjectEmail, subjectUserID, reason, jti, ipAddress, expiresAt).Scan(&id)
+	return id, err
+}
+
+// Function to end an impersonation and revoke its token
+func EndImpersonation(id int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var jti string
+	var expiresAt time.Time
+	err = tx.QueryRow(`
+		UPDATE impersonation_sessions SET ended_at = COALESCE(ended_at, NOW())
+		WHERE id = $1
+		RETURNING jti, expires_at`, id).Scan(&jti, &expiresAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("impersonation not found")
+	} else if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO revoked_tokens (jti, expires_at, revoked_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to check whether an impersonation is still running (not ended and not expired)
+func IsImpersonationActive(id int) (bool, error) {
+	var active bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM impersonation_sessions
+			WHERE id = $1 AND ended_at IS NULL AND expires_at > NOW()
+		)`, id).Scan(&active)
+	return active, err
+}
+
+// Function to record a request made while impersonating
+func RecordImpersonationAction(impersonationID int, method, path string, status int, ipAddress string) error {
+	_, err := database.DB.Exec(`
+		INSERT INTO impersonation_actions (impersonation_id, method, path, status, ip_address, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())`, impersonationID, method, path, status, ipAddress)
+	return err
+}
+
+// Function to list impersonations, newest first, optionally only those of one employee
+func GetImpersonations(subjectUserID *int, offset, limit int) ([]Impersonation, int, error) {
+	var total int
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*) FROM impersonation_sessions
+		WHERE $1::int IS NULL OR subject_user_id = $1`, subjectUserID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.actor_email, s.subject_email, s.subject_user_id, s.reason, s.ip_address,
+		       s.started_at, s.expires_at, s.ended_at,
+		       (SELECT COUNT(*) FROM impersonation_actions a WHERE a.impersonation_id = s.id)
+		FROM impersonation_sessions s
+		WHERE $1::int IS NULL OR s.subject_user_id = $1
+		ORDER BY s.started_at DESC, s.id DESC
+		OFFSET $2 LIMIT $3`, subjectUserID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	impersonations := []Impersonation{}
+	for rows.Next() {
+		var imp Impersonation
+		err := rows.Scan(&imp.ID, &imp.ActorEmail, &imp.SubjectEmail, &imp.SubjectUserID, &imp.Reason, &imp.IPAddress,
+			&imp.StartedAt, &imp.ExpiresAt, &imp.EndedAt, &imp.ActionCount)
+		if err != nil {
+			return nil, 0, err
+		}
+		impersonations = append(impersonations, imp)
+	}
+	return impersonations, total, rows.Err()
+}
+
+// Function to get one impersonation with every action taken during it
+func GetImpersonation(id int) (*Impersonation, error) {
+	var imp Impersonation
+	err := database.DB.QueryRow(`
+		SELECT id, actor_email, subject_email, subject_user_id, reason, ip_address, started_at, expires_at, ended_at
+		FROM impersonation_sessions WHERE id = $1`, id).Scan(
+		&imp.ID, &imp.ActorEmail, &imp.SubjectEmail, &imp.SubjectUserID, &imp.Reason, &imp.IPAddress,
+		&imp.StartedAt, &imp.ExpiresAt, &imp.EndedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT method, path, status, ip_address, created_at
+		FROM impersonation_actions WHERE impersonation_id = $1
+		ORDER BY created_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	imp.Actions = []ImpersonationAction{}
+	for rows.Next() {
+		var action ImpersonationAction
+		if err := rows.Scan(&action.Method, &action.Path, &action.Status, &action.IPAddress, &action.CreatedAt); err != nil {
+			return nil, err
+		}
+		imp.Actions = append(imp.Actions, action)
+	}
+	imp.ActionCount = len(imp.Actions)
+	return &imp, rows.Err()
+}
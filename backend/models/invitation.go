@@ -0,0 +1,84 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Function to record a new invitation, revoking any earlier pending one for the same employee
+func CreateInvitation(userID int, email, jti, invitedBy string, expiresAt time.Time) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE invitations SET revoked_at = NOW()
+		WHERE user_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO invitations (user_id, email, jti, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())`, userID, email, jti, invitedBy, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to accept an invitation: create the employee's login account with
+// the given password hash and role. Returns the account email.
+func AcceptInvitation(jti, passwordHash, role string) (string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var invitationID, userID int
+	var email string
+	err = tx.QueryRow(`
+		SELECT i.id, i.user_id, u.email FROM invitations i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.jti = $1 AND i.accepted_at IS NULL AND i.revoked_at IS NULL AND i.expires_at > NOW()
+		  AND u.deleted_at IS NULL
+		FOR UPDATE OF i`, jti).Scan(&invitationID, &userID, &email)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("invalid or expired invitation")
+	} else if err != nil {
+		return "", err
+	}
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM credentials WHERE user_id = $1 OR email = $2)", userID, email).Scan(&exists)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf("account already exists")
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO credentials (email, password_hash, role_id, user_id)
+		VALUES ($1, $2, (SELECT id FROM roles WHERE name = $3), $4)`, email, passwordHash, role, userID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec("UPDATE invitations SET accepted_at = NOW() WHERE id = $1", invitationID); err != nil {
+		return "", err
+	}
+
+	return email, tx.Commit()
+}
+
+// Function to check whether an employee already has a login account
+func UserHasAccount(userID int) (bool, error) {
+	var exists bool
+	err := database.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM credentials WHERE user_id = $1)", userID).Scan(&exists)
+	return exists, err
+}
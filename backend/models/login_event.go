@@ -0,0 +1,166 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Ways of logging in recorded in login_events.method
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodMFA       = "mfa"
+	LoginMethodSSO       = "sso"
+	LoginMethodMagicLink = "magic_link"
+)
+
+// Anomalies a login event can be flagged with
+const (
+	LoginFlagNewDevice         = "new_device"          // user agent never seen in a successful login of the account
+	LoginFlagNewIPRange        = "new_ip_range"        // IP range never seen in a successful login of the account
+	LoginFlagFailureBurst      = "failure_burst"       // more failures in a short window than a person could type
+	LoginFlagAfterFailureBurst = "after_failure_burst" // successful login shortly after a failure burst
+)
+
+// LoginEvent is one login attempt
+type LoginEvent struct {
+	ID            int64     `json:"id"`
+	Email         string    `json:"email"`
+	Success       bool      `json:"success"`
+	Method        string    `json:"method"`
+	FailureReason *string   `json:"failure_reason"`
+	IPAddress     *string   `json:"ip_address"`
+	UserAgent     *string   `json:"user_agent"`
+	Flags         []string  `json:"flags"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// LoginEventFilters narrows down GetLoginEvents; zero values do not filter
+type LoginEventFilters struct {
+	Email     string
+	Success   *bool
+	Method    string
+	IPAddress string
+	Flagged   bool
+	Flag      string
+	From      *time.Time
+	To        *time.Time
+}
+
+// Function to store a login attempt
+func RecordLoginEvent(event LoginEvent, ipRange string) error {
+	_, err := database.DB.Exec(`
+		INSERT INTO login_events (email, success, method, failure_reason, ip_address, ip_range, user_agent, flags, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())`,
+		event.Email, event.Success, event.Method, event.FailureReason, event.IPAddress, ipRange, event.UserAgent, pq.Array(event.Flags))
+	return err
+}
+
+// Function to check an account's earlier successful logins. Returns whether
+// there are any, and whether one came from the user agent and the IP range.
+func GetLoginFamiliarity(email, userAgent, ipRange string) (hasHistory, knownDevice, knownRange bool, err error) {
+	err = database.DB.QueryRow(`
+		SELECT COUNT(*) > 0,
+		       COALESCE(BOOL_OR(user_agent = $2), false),
+		       COALESCE(BOOL_OR(ip_range = $3), false)
+		FROM login_events WHERE LOWER(email) = LOWER($1) AND success`, email, userAgent, ipRange).Scan(&hasHistory, &knownDevice, &knownRange)
+	return
+}
+
+// Function to count failed logins for an email within the given window
+func CountRecentLoginFailures(email string, window time.Duration) (int, error) {
+	var count int
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*) FROM login_events
+		WHERE LOWER(email) = LOWER($1) AND NOT success AND created_at > NOW() - $2 * INTERVAL '1 second'`,
+		email, window.Seconds()).Scan(&count)
+	return count, err
+}
+
+// Function to get login events matching the filters, newest first, with the total count
+func GetLoginEvents(filters LoginEventFilters, offset, limit int) ([]LoginEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	argIndex := 1
+
+	if filters.Email != "" {
+		conditions = append(conditions, fmt.Sprintf("LOWER(email) = LOWER($%d)", argIndex))
+		args = append(args, filters.Email)
+		argIndex++
+	}
+	if filters.Success != nil {
+		conditions = append(conditions, fmt.Sprintf("success = $%d", argIndex))
+		args = append(args, *filters.Success)
+		argIndex++
+	}
+	if filters.Method != "" {
+		conditions = append(conditions, fmt.Sprintf("method = $%d", argIndex))
+		args = append(args, filters.Method)
+		argIndex++
+	}
+	if filters.IPAddress != "" {
+		conditions = append(conditions, fmt.Sprintf("ip_address = $%d", argIndex))
+		args = append(args, filters.IPAddress)
+		argIndex++
+	}
+	if filters.Flagged {
+		conditions = append(conditions, "cardinality(flags) > 0")
+	}
+	if filters.Flag != "" {
+		conditions = append(conditions, fmt.Sprintf("$%d = ANY(flags)", argIndex))
+		args = append(args, filters.Flag)
+		argIndex++
+	}
+	if filters.From != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argIndex))
+		args = append(args, *filters.From)
+		argIndex++
+	}
+	if filters.To != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", argIndex))
+		args = append(args, *filters.To)
+		argIndex++
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM login_events "+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, email, success, method, failure_reason, ip_address, user_agent, flags, created_at
+		FROM login_events %s
+		ORDER BY created_at DESC, id DESC
+		OFFSET $%d LIMIT $%d`, whereClause, argIndex, argIndex+1)
+	rows, err := database.DB.Query(query, append(args, offset, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []LoginEvent{}
+	for rows.Next() {
+		var event LoginEvent
+		err := rows.Scan(&event.ID, &event.Email, &event.Success, &event.Method, &event.FailureReason, &event.IPAddress,
+			&event.UserAgent, pq.Array(&event.Flags), &event.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, total, rows.Err()
+}
+
+// Function to remove login events older than the retention period
+func DeleteOldLoginEvents(retention time.Duration) error {
+	_, err := database.DB.Exec("DELETE FROM login_events WHERE created_at < NOW() - $1 * INTERVAL '1 second'", retention.Seconds())
+	return err
+}
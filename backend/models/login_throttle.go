@@ -0,0 +1,63 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"time"
+)
+
+// Scopes of failed-login counters
+const (
+	ThrottleAccount = "account"
+	ThrottleIP      = "ip"
+)
+
+type LoginThrottle struct {
+	Failures         int
+	SinceLastFailure time.Duration
+	LockedFor        time.Duration // zero when not locked
+}
+
+// Function to get the failed-login counter for an account or IP. A missing
+// counter is returned as zero failures.
+func GetLoginThrottle(scope, key string) (*LoginThrottle, error) {
+	var throttle LoginThrottle
+	var since, locked float64
+	err := database.DB.QueryRow(`
+		SELECT failures,
+		       EXTRACT(EPOCH FROM NOW() - last_failed_at),
+		       COALESCE(GREATEST(EXTRACT(EPOCH FROM locked_until - NOW()), 0), 0)
+		FROM login_throttles WHERE scope = $1 AND key = $2`, scope, key).Scan(
+		&throttle.Failures, &since, &locked)
+	if err == sql.ErrNoRows {
+		return &LoginThrottle{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	throttle.SinceLastFailure = time.Duration(since * float64(time.Second))
+	throttle.LockedFor = time.Duration(locked * float64(time.Second))
+	return &throttle, nil
+}
+
+// Function to count a failed login. Failures older than window start a new
+// count, and reaching maxFailures locks the key for lockout.
+func RecordLoginFailure(scope, key string, window time.Duration, maxFailures int, lockout time.Duration) error {
+	_, err := database.DB.Exec(`
+		INSERT INTO login_throttles AS t (scope, key, failures, last_failed_at, locked_until)
+		VALUES ($1, $2, 1, NOW(), CASE WHEN 1 >= $4 THEN NOW() + make_interval(secs => $5) END)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN t.last_failed_at < NOW() - make_interval(secs => $3) THEN 1 ELSE t.failures + 1 END,
+			last_failed_at = NOW(),
+			locked_until = CASE
+				WHEN (CASE WHEN t.last_failed_at < NOW() - make_interval(secs => $3) THEN 1 ELSE t.failures + 1 END) >= $4
+				THEN NOW() + make_interval(secs => $5)
+				ELSE t.locked_until
+			END`, scope, key, window.Seconds(), maxFailures, lockout.Seconds())
+	return err
+}
+
+// Function to reset the failed-login counter for an account or IP
+func ClearLoginFailures(scope, key string) error {
+	_, err := database.DB.Exec("DELETE FROM login_throttles WHERE scope = $1 AND key = $2", scope, key)
+	return err
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Function to record a new sign-in link, invalidating older unused ones for the same account
+func CreateMagicLink(email, jti, ip string, expiresAt time.Time) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE magic_links SET used_at = NOW() WHERE email = $1 AND used_at IS NULL", email); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO magic_links (email, jti, ip_address, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())`, email, jti, ip, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to check whether a sign-in link was sent to an account within the given window
+func MagicLinkSentWithin(email string, window time.Duration) (bool, error) {
+	var sent bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM magic_links
+		WHERE email = $1 AND created_at > NOW() - $2 * INTERVAL '1 second')`, email, window.Seconds()).Scan(&sent)
+	return sent, err
+}
+
+// Function to use a sign-in link. Each link works once. Returns the account email.
+func ConsumeMagicLink(jti string) (string, error) {
+	var email string
+	err := database.DB.QueryRow(`
+		UPDATE magic_links SET used_at = NOW()
+		WHERE jti = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING email`, jti).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("invalid or expired link")
+	}
+	return email, err
+}
+
+// Function to remove sign-in links that expired more than a day ago
+func DeleteExpiredMagicLinks() error {
+	_, err := database.DB.Exec("DELETE FROM magic_links WHERE expires_at < NOW() - INTERVAL '1 day'")
+	return err
+}
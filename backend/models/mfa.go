@@ -0,0 +1,123 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+)
+
+type MFASettings struct {
+	CredentialID           int
+	Secret                 string
+	Enabled                bool
+	LastStep               int64
+	RecoveryCodesRemaining int
+}
+
+// Function to get the TOTP settings of a login account
+func GetMFASettings(email string) (*MFASettings, error) {
+	var settings MFASettings
+	var secret sql.NullString
+	err := database.DB.QueryRow(`
+		SELECT c.id, c.totp_secret, c.totp_enabled, c.totp_last_step,
+		       (SELECT COUNT(*) FROM mfa_recovery_codes r WHERE r.credential_id = c.id AND r.used_at IS NULL)
+		FROM credentials c WHERE c.email = $1`, email).Scan(
+		&settings.CredentialID, &secret, &settings.Enabled, &settings.LastStep, &settings.RecoveryCodesRemaining)
+	if err != nil {
+		return nil, err
+	}
+	settings.Secret = secret.String
+	return &settings, nil
+}
+
+// Function to store a TOTP secret that still has to be confirmed with a code
+func SetPendingTOTPSecret(credentialID int, secret string) error {
+	_, err := database.DB.Exec("UPDATE credentials SET totp_secret = $1, totp_enabled = FALSE, totp_last_step = 0 WHERE id = $2", secret, credentialID)
+	return err
+}
+
+// Function to turn on TOTP after the first code was verified and store the hashed recovery codes
+func EnableTOTP(credentialID int, step int64, codeHashes []string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE credentials SET totp_enabled = TRUE, totp_last_step = $1 WHERE id = $2", step, credentialID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, credentialID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to turn off TOTP and drop the recovery codes
+func DisableTOTP(credentialID int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE credentials SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = $1", credentialID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE credential_id = $1", credentialID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to replace all recovery codes of an account
+func ReplaceRecoveryCodes(credentialID int, codeHashes []string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, credentialID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, credentialID int, codeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE credential_id = $1", credentialID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		_, err := tx.Exec("INSERT INTO mfa_recovery_codes (credential_id, code_hash, created_at) VALUES ($1, $2, NOW())", credentialID, hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to record a used TOTP time step. Returns false if the step (or a
+// later one) was already used, so a code cannot be replayed.
+func UseTOTPStep(credentialID int, step int64) (bool, error) {
+	result, err := database.DB.Exec("UPDATE credentials SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1", step, credentialID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Function to consume a recovery code. Returns false if it does not exist or was already used.
+func UseRecoveryCode(credentialID int, codeHash string) (bool, error) {
+	result, err := database.DB.Exec(`
+		UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE credential_id = $1 AND code_hash = $2 AND used_at IS NULL`, credentialID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Function to remember a single sign-on attempt until the provider redirects back
+func CreateOIDCLoginState(stateHash, nonce, codeVerifier string, expiresAt time.Time) error {
+	_, err := database.DB.Exec(`
+		INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())`, stateHash, nonce, codeVerifier, expiresAt)
+	return err
+}
+
+// Function to take a pending sign-on attempt by its state hash. Each state can
+// be used once. Returns the nonce and PKCE code verifier.
+func ConsumeOIDCLoginState(stateHash string) (string, string, error) {
+	var nonce, codeVerifier string
+	err := database.DB.QueryRow(`
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1 AND expires_at > NOW()
+		RETURNING nonce, code_verifier`, stateHash).Scan(&nonce, &codeVerifier)
+	if err == sql.ErrNoRows {
+		return "", "", fmt.Errorf("invalid or expired state")
+	}
+	return nonce, codeVerifier, err
+}
+
+// Function to remove sign-on attempts that were never completed
+func DeleteExpiredOIDCLoginStates() error {
+	_, err := database.DB.Exec("DELETE FROM oidc_login_states WHERE expires_at < NOW()")
+	return err
+}
+
+// Function to find the login account for an identity provider email (case
+// insensitive). Returns the account email and the subject it is linked to, if any.
+func GetSSOAccount(email string) (string, sql.NullString, error) {
+	var accountEmail string
+	var subject sql.NullString
+	err := database.DB.QueryRow(`
+		SELECT email, oidc_subject FROM credentials
+		WHERE LOWER(email) = LOWER($1)`, email).Scan(&accountEmail, &subject)
+	return accountEmail, subject, err
+}
+
+// Function to link a login account to an identity provider subject on its first single sign-on
+func LinkOIDCSubject(email, subject string) error {
+	_, err := database.DB.Exec("UPDATE credentials SET oidc_subject = $2 WHERE email = $1 AND oidc_subject IS NULL", email, subject)
+	return err
+}
+
+// Function to find the employee record for an identity provider email (case
+// insensitive). Returns the record ID and its stored email.
+func GetUserByEmailInsensitive(email string) (int, string, error) {
+	var id int
+	var userEmail string
+	err := database.DB.QueryRow("SELECT id, email FROM users WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL", email).Scan(&id, &userEmail)
+	return id, userEmail, err
+}
+
+// Function to create the login account of an employee signing in through
+// single sign-on for the first time. Returns "account already exists" if
+// another request created it first.
+func CreateSSOAccount(userID int, email, passwordHash, subject, role string) error {
+	result, err := database.DB.Exec(`
+		INSERT INTO credentials (email, password_hash, role_id, user_id, oidc_subject)
+		VALUES ($1, $2, (SELECT id FROM roles WHERE name = $3), $4, $5)
+		ON CONFLICT DO NOTHING`, email, passwordHash, role, userID, subject)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("account already exists")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"fmt"
+)
+
+// Function to get an account's current password hash followed by its
+// earlier ones, newest first, up to limit hashes in total
+func GetPasswordHashes(email string, limit int) ([]string, error) {
+	rows, err := database.DB.Query(`
+		SELECT password_hash FROM (
+			SELECT c.password_hash, 0 AS pos, NOW() AS created_at FROM credentials c WHERE c.email = $1
+			UNION ALL
+			SELECT h.password_hash, 1 AS pos, h.created_at FROM password_history h
+			JOIN credentials c ON c.id = h.credential_id WHERE c.email = $1
+		) hashes
+		ORDER BY pos, created_at DESC
+		LIMIT $2`, email, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// Function to change an account's password, keeping the old hash in its
+// history so it cannot be reused. historySize counts the current password.
+func UpdatePassword(email, passwordHash string, historySize int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setPasswordTx(tx, email, passwordHash, historySize); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to replace an account's password hash with a new hash of the same
+// password (e.g. stronger parameters). Nothing changes if the password was
+// changed in the meantime. The old hash is not kept in the history.
+func UpgradePasswordHash(email, oldHash, newHash string) error {
+	_, err := database.DB.Exec("UPDATE credentials SET password_hash = $3 WHERE email = $1 AND password_hash = $2", email, oldHash, newHash)
+	return err
+}
+
+func setPasswordTx(tx *sql.Tx, email, passwordHash string, historySize int) error {
+	var credentialID int
+	var oldHash string
+	err := tx.QueryRow("SELECT id, password_hash FROM credentials WHERE email = $1 AND is_active FOR UPDATE", email).Scan(&credentialID, &oldHash)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("account not found")
+	} else if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO password_history (credential_id, password_hash, created_at) VALUES ($1, $2, NOW())", credentialID, oldHash)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE credentials SET password_hash = $1 WHERE id = $2", passwordHash, credentialID); err != nil {
+		return err
+	}
+
+	// The current password is one of the remembered ones
+	_, err = tx.Exec(`
+		DELETE FROM password_history WHERE credential_id = $1 AND id NOT IN (
+			SELECT id FROM password_history WHERE credential_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2
+		)`, credentialID, max(historySize-1, 0))
+	return err
+}
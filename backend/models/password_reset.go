@@ -0,0 +1,86 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Function to store a hashed password reset token, invalidating older unused ones
+func CreatePasswordResetToken(email, tokenHash string, expiresAt time.Time) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE password_reset_tokens SET used_at = NOW() WHERE email = $1 AND used_at IS NULL", email); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO password_reset_tokens (email, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, NOW())`, email, tokenHash, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to check whether a reset link was sent to an email within the last window
+func PasswordResetSentWithin(email string, window time.Duration) (bool, error) {
+	var sent bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM password_reset_tokens
+		WHERE email = $1 AND created_at > NOW() - $2 * INTERVAL '1 second')`, email, window.Seconds()).Scan(&sent)
+	return sent, err
+}
+
+// Function to get the email a valid, unused reset token belongs to
+func GetPasswordResetEmail(tokenHash string) (string, error) {
+	var email string
+	err := database.DB.QueryRow(`
+		SELECT email FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()`, tokenHash).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("invalid or expired token")
+	}
+	return email, err
+}
+
+// Function to consume a password reset token and set the new password hash.
+// Returns the account email on success.
+func ResetPassword(tokenHash, passwordHash string, historySize int) (string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRow(`
+		UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING email`, tokenHash).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("invalid or expired token")
+	} else if err != nil {
+		return "", err
+	}
+
+	if err := setPasswordTx(tx, email, passwordHash, historySize); err != nil {
+		if err.Error() == "account not found" {
+			return "", fmt.Errorf("invalid or expired token")
+		}
+		return "", err
+	}
+
+	return email, tx.Commit()
+}
+
+// Function to remove password reset tokens that expired more than a day ago
+func DeleteExpiredPasswordResetTokens() error {
+	_, err := database.DB.Exec("DELETE FROM password_reset_tokens WHERE expires_at < NOW() - INTERVAL '1 day'")
+	return err
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"fmt"
+)
+
+// Built-in roles seeded by migrations/002_roles_permissions.sql
+const (
+	RoleAdmin             = "admin"
+	RoleHRManager         = "hr_manager"
+	RoleDepartmentManager = "department_manager"
+	RoleEmployee          = "employee"
+)
+
+// Built-in permissions seeded by migrations/002_roles_permissions.sql
+const (
+	PermUsersRead   = "users:read"
+	PermUsersWrite  = "users:write"
+	PermUsersDelete = "users:delete"
+	PermRolesManage = "roles:manage"
+
+	// Seeded by migrations/010_api_tokens.sql
+	PermUsersSalaryRead = "users:salary:read"
+)
+
+// Rank of the built-in roles. Accounts can only be taken over (email changed,
+// deactivated) by callers of a higher rank or with roles:manage; other roles rank lowest.
+var roleRanks = map[string]int{
+	RoleAdmin:             4,
+	RoleHRManager:         3,
+	RoleDepartmentManager: 2,
+	RoleEmployee:          1,
+}
+
+// Function to check whether role ranks above other
+func RoleOutranks(role, other string) bool {
+	return roleRanks[role] > roleRanks[other]
+}
+
+type Role struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Function to get the role assigned to an active login account. Deactivated
+// accounts are reported as sql.ErrNoRows.
+func GetActiveRoleByEmail(email string) (string, error) {
+	var role string
+	err := database.DB.QueryRow(`
+		SELECT r.name FROM credentials c
+		JOIN roles r ON r.id = c.role_id
+		WHERE c.email = $1 AND c.is_active`, email).Scan(&role)
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// Function to get the role of the login account linked to an employee
+// record (sql.ErrNoRows if there is none)
+func GetAccountRoleByUserID(userID int) (string, error) {
+	var role string
+	err := database.DB.QueryRow(`
+		SELECT r.name FROM credentials c
+		JOIN roles r ON r.id = c.role_id
+		WHERE c.user_id = $1`, userID).Scan(&role)
+	return role, err
+}
+
+// Function to get the permission names granted to a role
+func GetRolePermissions(role string) ([]string, error) {
+	rows, err := database.DB.Query(`
+		SELECT p.name FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		JOIN roles r ON r.id = rp.role_id
+		WHERE r.name = $1
+		ORDER BY p.name`, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, name)
+	}
+	return permissions, rows.Err()
+}
+
+// Function to get the names of every known permission
+func GetPermissionNames() ([]string, error) {
+	rows, err := database.DB.Query("SELECT name FROM permissions ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, name)
+	}
+	return permissions, rows.Err()
+}
+
+// Function to check whether a role grants a permission
+func RoleHasPermission(role, permission string) (bool, error) {
+	var granted bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM role_permissions rp
+			JOIN roles r ON r.id = rp.role_id
+			JOIN permissions p ON p.id = rp.permission_id
+			WHERE r.name = $1 AND p.name = $2
+		)`, role, permission).Scan(&granted)
+	return granted, err
+}
+
+// Function to list every role with its permissions
+func GetRoles() ([]Role, error) {
+	rows, err := database.DB.Query("SELECT id, name, description FROM roles ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range roles {
+		permissions, err := GetRolePermissions(roles[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		roles[i].Permissions = permissions
+	}
+	return roles, nil
+}
+
+// Function to assign a role to the login account with the given email
+func SetRoleByEmail(email, role string) error {
+	return setRole("email = $2", email, role)
+}
+
+// Function to assign a role to the login account linked to an employee record
+func SetRoleByUserID(userID int, role string) error {
+	return setRole("user_id = $2", userID, role)
+}
+
+func setRole(where string, key interface{}, role string) error {
+	var roleID int
+	err := database.DB.QueryRow("SELECT id FROM roles WHERE name = $1", role).Scan(&roleID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("role not found")
+	} else if err != nil {
+		return err
+	}
+
+	result, err := database.DB.Exec("UPDATE credentials SET role_id = $1 WHERE "+where, roleID, key)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("account not found")
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"time"
+)
+
+type SigningKey struct {
+	KID         string
+	Algorithm   string
+	PrivateKey  string // PKCS #8, PEM encoded
+	ActivatesAt time.Time
+	Active      bool // activates_at has passed
+}
+
+// Function to get every key that may still verify tokens, newest activation first
+func GetSigningKeys() ([]SigningKey, error) {
+	rows, err := database.DB.Query(`
+		SELECT kid, algorithm, private_key, activates_at, activates_at <= NOW()
+		FROM signing_keys
+		WHERE expires_at IS NULL OR expires_at > NOW()
+		ORDER BY activates_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []SigningKey{}
+	for rows.Next() {
+		var key SigningKey
+		if err := rows.Scan(&key.KID, &key.Algorithm, &key.PrivateKey, &key.ActivatesAt, &key.Active); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Function to rotate the signing keys. A new key (made by newKey) is added
+// when there is none, when force is set, or when the current key is older
+// than interval minus prepublish; it starts signing prepublish later so
+// verifiers can fetch it first (immediately if forced or if there is no key).
+// Keys replaced by a newer active key are kept for retention, then deleted;
+// with no retention they are deleted at once, so they verify nothing more.
+func RotateSigningKeys(newKey func() (*SigningKey, error), interval, prepublish, retention time.Duration, force bool) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serialise rotations between backend instances sharing the database
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('signing_keys'))"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM signing_keys WHERE expires_at < NOW()"); err != nil {
+		return err
+	}
+
+	var hasActive, hasPending bool
+	var activeAge float64
+	err = tx.QueryRow(`
+		SELECT
+			EXISTS (SELECT 1 FROM signing_keys WHERE activates_at <= NOW() AND expires_at IS NULL),
+			EXISTS (SELECT 1 FROM signing_keys WHERE activates_at > NOW()),
+			COALESCE((SELECT EXTRACT(EPOCH FROM NOW() - MAX(activates_at)) FROM signing_keys WHERE activates_at <= NOW() AND expires_at IS NULL), 0)`).Scan(
+		&hasActive, &hasPending, &activeAge)
+	if err != nil {
+		return err
+	}
+
+	activateIn := time.Duration(0)
+	create := false
+	switch {
+	case force:
+		if _, err := tx.Exec("DELETE FROM signing_keys WHERE activates_at > NOW()"); err != nil {
+			return err
+		}
+		create = true
+	case !hasActive:
+		create = true
+	case !hasPending && time.Duration(activeAge*float64(time.Second)) >= interval-prepublish:
+		create = true
+		activateIn = prepublish
+	}
+
+	if create {
+		key, err := newKey()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO signing_keys (kid, algorithm, private_key, created_at, activates_at)
+			VALUES ($1, $2, $3, NOW(), NOW() + make_interval(secs => $4))`,
+			key.KID, key.Algorithm, key.PrivateKey, activateIn.Seconds())
+		if err != nil {
+			return err
+		}
+	}
+
+	if retention <= 0 {
+		_, err = tx.Exec(`
+			DELETE FROM signing_keys
+			WHERE activates_at < (SELECT MAX(activates_at) FROM signing_keys WHERE activates_at <= NOW())`)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE signing_keys SET expires_at = NOW() + make_interval(secs => $1)
+			WHERE expires_at IS NULL
+			  AND activates_at < (SELECT MAX(activates_at) FROM signing_keys WHERE activates_at <= NOW())`,
+			retention.Seconds())
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
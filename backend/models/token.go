@@ -0,0 +1,207 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type RefreshToken struct {
+	ID        int
+	FamilyID  string
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	RevokedAt sql.NullTime
+}
+
+// A signed-in session, i.e. a refresh token family that can still be refreshed
+type Session struct {
+	ID         string    `json:"id"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+// Function to start a new refresh token family (one per login), recording where the login came from
+func CreateTokenFamily(id, email, ipAddress, userAgent string) error {
+	_, err := database.DB.Exec(`
+		INSERT INTO token_families (id, email, ip_address, user_agent, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())`, id, email, ipAddress, userAgent)
+	return err
+}
+
+// Function to record that a session was used. Writes at most once a minute per session.
+func TouchSession(familyID string) error {
+	_, err := database.DB.Exec(`
+		UPDATE token_families SET last_seen_at = NOW()
+		WHERE id = $1 AND (last_seen_at IS NULL OR last_seen_at < NOW() - INTERVAL '1 minute')`, familyID)
+	return err
+}
+
+// Function to list the active sessions of an account, most recently used first
+func GetSessions(email string) ([]Session, error) {
+	rows, err := database.DB.Query(`
+		SELECT f.id, COALESCE(f.ip_address, ''), COALESCE(f.user_agent, ''), f.created_at, COALESCE(f.last_seen_at, f.created_at)
+		FROM token_families f
+		WHERE f.email = $1 AND f.revoked_at IS NULL
+		  AND EXISTS (
+			SELECT 1 FROM refresh_tokens r
+			WHERE r.family_id = f.id AND r.used_at IS NULL AND r.revoked_at IS NULL AND r.expires_at > NOW()
+		  )
+		ORDER BY 5 DESC`, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.ID, &session.IPAddress, &session.UserAgent, &session.CreatedAt, &session.LastSeenAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Function to end one session of an account. Returns "session not found" if
+// the account has no active session with that ID.
+func RevokeSession(email, familyID string) error {
+	var exists bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM token_families WHERE id = $1 AND email = $2 AND revoked_at IS NULL)`,
+		familyID, email).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("session not found")
+	}
+	return RevokeTokenFamily(familyID)
+}
+
+// Function to store a hashed refresh token in a family
+func CreateRefreshToken(tokenHash, familyID, email string, expiresAt time.Time) error {
+	_, err := database.DB.Exec(`
+		INSERT INTO refresh_tokens (token_hash, family_id, email, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())`, tokenHash, familyID, email, expiresAt)
+	return err
+}
+
+// Function to look up a refresh token by its hash
+func GetRefreshTokenByHash(tokenHash string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := database.DB.QueryRow(`
+		SELECT id, family_id, email, expires_at, used_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = $1`, tokenHash).Scan(
+		&token.ID, &token.FamilyID, &token.Email, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Function to mark a refresh token as used and store its replacement in the
+// same family. Returns "refresh token already used" if another request
+// rotated it first, which callers must treat as reuse.
+func RotateRefreshToken(oldID int, newHash, familyID, email string, expiresAt time.Time) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL", oldID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("refresh token already used")
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO refresh_tokens (token_hash, family_id, email, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())`, newHash, familyID, email, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to revoke a refresh token family and every token in it
+func RevokeTokenFamily(familyID string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE token_families SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL", familyID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL", familyID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to add an access token's jti to the revocation list
+func RevokeAccessToken(jti string, expiresAt time.Time) error {
+	_, err := database.DB.Exec(`
+		INSERT INTO revoked_tokens (jti, expires_at, revoked_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+	return err
+}
+
+// Function to check whether an access token was revoked directly or through its family
+func IsAccessTokenRevoked(jti, familyID string) (bool, error) {
+	var revoked bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		    OR EXISTS (SELECT 1 FROM token_families WHERE id = $2 AND revoked_at IS NOT NULL)`, jti, familyID).Scan(&revoked)
+	return revoked, err
+}
+
+// Function to remove expired refresh tokens and revocation entries
+func DeleteExpiredTokens() error {
+	if _, err := database.DB.Exec("DELETE FROM revoked_tokens WHERE expires_at < NOW()"); err != nil {
+		return err
+	}
+	if _, err := database.DB.Exec("DELETE FROM refresh_tokens WHERE expires_at < NOW()"); err != nil {
+		return err
+	}
+	_, err := database.DB.Exec(`
+		DELETE FROM token_families f
+		WHERE NOT EXISTS (SELECT 1 FROM refresh_tokens r WHERE r.family_id = f.id)`)
+	return err
+}
+
+// Function to revoke every refresh token family of an account (sign out everywhere)
+func RevokeAllTokenFamilies(email string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeAllTokenFamiliesTx(tx, email); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func revokeAllTokenFamiliesTx(tx *sql.Tx, email string) error {
+	if _, err := tx.Exec("UPDATE token_families SET revoked_at = NOW() WHERE email = $1 AND revoked_at IS NULL", email); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE email = $1 AND revoked_at IS NULL", email)
+	return err
+}
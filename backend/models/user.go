@@ -5,25 +5,36 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type Users struct {
-	ID                  int       `json:"id"`
-	First_Name          string    `json:"first_name"`
-	Last_Name           string    `json:"last_name"`
-	Gender              string    `json:"gender"`
-	Location            string    `json:"location"`
-	Email               string    `json:"email"`
-	Phone               string    `json:"phone"`
-	Department          string    `json:"department"`
-	Role                string    `json:"role"`
-	Salary              int       `json:"salary"`
-	Join_Date           string    `json:"join_date"`
-	Years_of_Experience int       `json:"years_of_experience"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
+	ID                  int        `json:"id"`
+	First_Name          string     `json:"first_name"`
+	Last_Name           string     `json:"last_name"`
+	Gender              string     `json:"gender"`
+	Location            string     `json:"location"`
+	Email               string     `json:"email"`
+	Phone               string     `json:"phone"`
+	Department          string     `json:"department"`
+	Role                string     `json:"role"`
+	Salary              int        `json:"salary"`
+	Join_Date           string     `json:"join_date"`
+	Years_of_Experience int        `json:"years_of_experience"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	Version             int        `json:"-"`
+	ETag                string     `json:"etag"` // quoted version, as sent in the ETag header
+	DeletedAt           *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy           *string    `json:"deleted_by,omitempty"`
+}
+
+// Function to get the entity tag of an employee record version
+func UserETag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
 }
 
 type UserFilters struct {
@@ -41,13 +52,36 @@ type UserFilters struct {
 	JoinDateTo     string
 	ExperienceFrom *int
 	ExperienceTo   *int
+	Trashed        bool       // list deleted records (most recently deleted first) instead of live ones
+	Sort           []UserSort // order of the listing before the ID; by ID (or deletion time) if empty
 }
 
-// Function to get all users from the database
-func GetAllUsers(offset, limit int, filters UserFilters) ([]Users, int, error) {
-	var users []Users
-	var total int
+// UserSort orders a listing of employee records by one column
+type UserSort struct {
+	Column     string // one of SortableUserColumns (or deleted_at, for the trash)
+	Descending bool
+}
+
+// Columns employee listings can be sorted by. They are not null, so with the
+// ID as the last key every record has a fixed place in the order.
+var SortableUserColumns = []string{
+	"id", "first_name", "last_name", "gender", "location", "email", "phone", "department",
+	"role", "salary", "join_date", "years_of_experience", "created_at", "updated_at",
+}
 
+// Function to check whether a listing can be sorted by column
+func IsSortableUserColumn(column string) bool {
+	for _, sortable := range SortableUserColumns {
+		if column == sortable {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to build the WHERE clause of a listing of employee records from
+// filters, with the arguments of the clause's placeholders
+func userFilterClause(filters UserFilters) (string, []interface{}) {
 	// Build the WHERE clause dynamically
 	var conditions []string
 	var args []interface{}
@@ -144,46 +178,272 @@ func GetAllUsers(offset, limit int, filters UserFilters) ([]Users, int, error) {
 		argIndex++
 	}
 
-	whereClause := ""
-	if len(conditions) > 0 {
-		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	if filters.Trashed {
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "deleted_at IS NULL")
 	}
 
-	query := fmt.Sprintf("SELECT id, first_name, last_name, gender, location, email, phone, department, role, salary, join_date, years_of_experience, created_at, updated_at FROM users %s ORDER BY id LIMIT $%d OFFSET $%d", whereClause, argIndex, argIndex+1)
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM users %s", whereClause)
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
 
-	// Get total count
-	err := database.DB.QueryRow(countQuery, args...).Scan(&total)
+// Function to get the keys a listing is ordered by: filters.Sort (skipping
+// columns that are not sortable, so they never reach the query) followed by
+// the ID, which makes the order total so records with equal values keep
+// their place on every page. Without Sort, by ID, or most recently deleted first in the trash.
+func UserSortKeys(filters UserFilters) []UserSort {
+	keys := []UserSort{}
+	if len(filters.Sort) == 0 && filters.Trashed {
+		keys = append(keys, UserSort{Column: "deleted_at", Descending: true})
+	}
+	for _, key := range filters.Sort {
+		if !IsSortableUserColumn(key.Column) {
+			continue
+		}
+		keys = append(keys, key)
+		if key.Column == "id" {
+			return keys
+		}
+	}
+	return append(keys, UserSort{Column: "id"})
+}
+
+// Function to build an ORDER BY list from sort keys, optionally reversed
+func userOrderBy(keys []UserSort, reverse bool) string {
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		if key.Descending != reverse {
+			terms[i] = key.Column + " DESC"
+		} else {
+			terms[i] = key.Column + " ASC"
+		}
+	}
+	return strings.Join(terms, ", ")
+}
+
+// Function to get the values of the sort keys of a record, as text, to
+// mark its position in a listing (see UserPage)
+func UserSortValues(user *Users, keys []UserSort) []string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		switch key.Column {
+		case "id":
+			values[i] = strconv.Itoa(user.ID)
+		case "first_name":
+			values[i] = user.First_Name
+		case "last_name":
+			values[i] = user.Last_Name
+		case "gender":
+			values[i] = user.Gender
+		case "location":
+			values[i] = user.Location
+		case "email":
+			values[i] = user.Email
+		case "phone":
+			values[i] = user.Phone
+		case "department":
+			values[i] = user.Department
+		case "role":
+			values[i] = user.Role
+		case "salary":
+			values[i] = strconv.Itoa(user.Salary)
+		case "join_date":
+			values[i] = user.Join_Date
+		case "years_of_experience":
+			values[i] = strconv.Itoa(user.Years_of_Experience)
+		case "created_at":
+			values[i] = user.CreatedAt.Format(time.RFC3339Nano)
+		case "updated_at":
+			values[i] = user.UpdatedAt.Format(time.RFC3339Nano)
+		case "deleted_at":
+			if user.DeletedAt != nil {
+				values[i] = user.DeletedAt.Format(time.RFC3339Nano)
+			}
+		}
+	}
+	return values
+}
+
+// Function to build the condition selecting the records that come after
+// (or, reversed, before) the position given by the values of the sort keys:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., with < for descending keys.
+// Placeholders are numbered from argIndex.
+func userKeysetCondition(keys []UserSort, values []string, reverse bool, argIndex int) (string, []interface{}) {
+	var alternatives []string
+	var args []interface{}
+	for i, key := range keys {
+		terms := []string{}
+		for j := 0; j < i; j++ {
+			terms = append(terms, fmt.Sprintf("%s = $%d", keys[j].Column, argIndex+j))
+		}
+		operator := ">"
+		if key.Descending != reverse {
+			operator = "<"
+		}
+		terms = append(terms, fmt.Sprintf("%s %s $%d", key.Column, operator, argIndex+i))
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+		args = append(args, values[i])
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// UserPage selects one page of a listing of employee records: Limit records
+// from Offset, or right after or before the record whose sort key values
+// (UserSortValues) are in After or Before. Keyset pages stay correct when
+// records are added or removed meanwhile and do not get slower further on.
+type UserPage struct {
+	Offset     int
+	Limit      int
+	After      []string
+	Before     []string
+	CountTotal bool // also count all matching records, which is slow for large listings
+}
+
+// Function to get one page of the employee records matching filters. Also
+// returns the number of matching records (-1 unless page.CountTotal) and
+// whether there are more records beyond the page, i.e. after it or, for
+// page.Before, before it.
+func GetUsersPage(filters UserFilters, page UserPage) ([]Users, int, bool, error) {
+	keys := UserSortKeys(filters)
+	whereClause, args := userFilterClause(filters)
+
+	total := -1
+	if page.CountTotal {
+		if err := database.DB.QueryRow("SELECT COUNT(*) FROM users "+whereClause, args...).Scan(&total); err != nil {
+			return nil, 0, false, err
+		}
+	}
+
+	position, reverse := page.After, false
+	if page.Before != nil {
+		position, reverse = page.Before, true
+	}
+	if position != nil {
+		if len(position) != len(keys) {
+			return nil, 0, false, fmt.Errorf("invalid position")
+		}
+		condition, keysetArgs := userKeysetCondition(keys, position, reverse, len(args)+1)
+		whereClause += " AND " + condition
+		args = append(args, keysetArgs...)
+	}
+
+	// One record more than the page shows whether there are more
+	argIndex := len(args) + 1
+	query := fmt.Sprintf("SELECT %s FROM users %s ORDER BY %s LIMIT $%d OFFSET $%d", userColumns, whereClause, userOrderBy(keys, reverse), argIndex, argIndex+1)
+	rows, err := database.DB.Query(query, append(args, page.Limit+1, page.Offset)...)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, false, err
 	}
+	defer rows.Close()
+
+	users := []Users{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, false, err
+	}
+
+	more := len(users) > page.Limit
+	if more {
+		users = users[:page.Limit]
+	}
+	if reverse {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+	return users, total, more, nil
+}
 
-	// Get paginated users
-	rows, err := database.DB.Query(query, append(args, limit, offset)...)
+// Function to get all users from the database
+func GetAllUsers(offset, limit int, filters UserFilters) ([]Users, int, error) {
+	users, total, _, err := GetUsersPage(filters, UserPage{Offset: offset, Limit: limit, CountTotal: true})
 	if err != nil {
 		return nil, 0, err
 	}
+	return users, total, nil
+}
 
+// Function to call fn with every employee record matching filters, in the
+// order of GetAllUsers. Rows are read from the database as they are needed,
+// so the result is never held in memory; an error from fn stops the listing.
+func EachUser(filters UserFilters, fn func(user *Users) error) error {
+	whereClause, args := userFilterClause(filters)
+	rows, err := database.DB.Query(fmt.Sprintf("SELECT %s FROM users %s ORDER BY %s", userColumns, whereClause, userOrderBy(UserSortKeys(filters), false)), args...)
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var user Users
-		if err := rows.Scan(&user.ID, &user.First_Name, &user.Last_Name, &user.Gender, &user.Location, &user.Email, &user.Phone, &user.Department, &user.Role, &user.Salary, &user.Join_Date, &user.Years_of_Experience, &user.CreatedAt, &user.UpdatedAt); err != nil {
-			return nil, 0, err
+		user, err := scanUser(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
 		}
-		users = append(users, user)
 	}
+	return rows.Err()
+}
 
-	return users, total, nil
+// NewUser holds the fields of an employee record to create
+type NewUser struct {
+	First_Name          string
+	Last_Name           string
+	Gender              string
+	Location            string
+	Email               string
+	Phone               string
+	Department          string
+	Role                string
+	Salary              int
+	Join_Date           string
+	Years_of_Experience int
+}
+
+// Function to insert a new user into the database. An existing login account
+// with the same email (e.g. the bootstrap admin) is linked to the new record.
+// The creation is recorded in audit_events as made by actor.
+func CreateUser(actor AuditActor, first_name, last_name, gender, location, email, phone, department, role string, salary int, join_date string, years_of_experience int) (int, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := createUserTx(tx, actor, NewUser{
+		First_Name: first_name, Last_Name: last_name, Gender: gender, Location: location, Email: email, Phone: phone,
+		Department: department, Role: role, Salary: salary, Join_Date: join_date, Years_of_Experience: years_of_experience,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	log.Printf("Inserted user with ID: %d", id)
+	return id, nil
 }
 
-// Function to insert a new user into the database
-func CreateUser(first_name, last_name, gender, location, email, phone, department, role string, salary int, join_date string, years_of_experience int) (int, error) {
+// Function to insert a new user within tx after checking that the email and
+// phone number are not taken. Returns the new record's ID.
+func createUserTx(tx *sql.Tx, actor AuditActor, newUser NewUser) (int, error) {
 	// Check if email already exists
-	emailCheckQuery := "SELECT id FROM users WHERE email = $1"
+	emailCheckQuery := "SELECT id, deleted_at IS NOT NULL FROM users WHERE email = $1"
 	var existingID int
-	err := database.DB.QueryRow(emailCheckQuery, email).Scan(&existingID)
-	if err == nil {
+	var existingDeleted bool
+	err := tx.QueryRow(emailCheckQuery, newUser.Email).Scan(&existingID, &existingDeleted)
+	if err == nil && existingDeleted {
+		// The record is in the trash and can be restored instead
+		return 0, fmt.Errorf("email belongs to a deleted user")
+	} else if err == nil {
 		// If no error, it means the email already exists
 		return 0, fmt.Errorf("email already exists")
 	} else if err != sql.ErrNoRows {
@@ -191,10 +451,26 @@ func CreateUser(first_name, last_name, gender, location, email, phone, departmen
 		return 0, err
 	}
 
+	// A login account that already uses this email is linked to the new
+	// record, which lets the record's editors take it over (password resets
+	// go to the record's email). That is refused unless actor may manage the account.
+	var accountRole string
+	var accountLinked bool
+	err = tx.QueryRow(`
+		SELECT r.name, c.user_id IS NOT NULL FROM credentials c
+		JOIN roles r ON r.id = c.role_id
+		WHERE c.email = $1`, newUser.Email).Scan(&accountRole, &accountLinked)
+	if err == nil && (accountLinked || !actor.MayManageAccount(accountRole)) {
+		return 0, fmt.Errorf("email belongs to a login account")
+	} else if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	linkAccount := err == nil
+
 	// Check if phone number already exists
 	phoneCheckQuery := "SELECT id FROM users WHERE phone = $1"
 	var existingID2 int
-	err2 := database.DB.QueryRow(phoneCheckQuery, phone).Scan(&existingID2)
+	err2 := tx.QueryRow(phoneCheckQuery, newUser.Phone).Scan(&existingID2)
 	if err2 == nil {
 		// If no error, it means the phone number already exists
 		return 0, fmt.Errorf("phone already exists")
@@ -209,27 +485,403 @@ func CreateUser(first_name, last_name, gender, location, email, phone, departmen
         (first_name, last_name, gender, location, email, phone, department, role, salary, join_date, years_of_experience, created_at, updated_at) 
         VALUES 
         ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW()) 
-        RETURNING id`
-	var id int
-	err = database.DB.QueryRow(insertQuery, first_name, last_name, gender, location, email, phone, department, role, salary, join_date, years_of_experience).Scan(&id)
+        RETURNING ` + userColumns
+	user, err := scanUser(tx.QueryRow(insertQuery, newUser.First_Name, newUser.Last_Name, newUser.Gender, newUser.Location, newUser.Email,
+		newUser.Phone, newUser.Department, newUser.Role, newUser.Salary, newUser.Join_Date, newUser.Years_of_Experience))
 	if err != nil {
 		log.Printf("Error inserting user into database: %v", err)
 		return 0, err
 	}
-	log.Printf("Inserted user with ID: %d", id)
-	return id, nil
+
+	if err := recordAuditEventTx(tx, actor, AuditUserCreated, user.ID, diffUser(nil, user)); err != nil {
+		return 0, err
+	}
+
+	if linkAccount {
+		if _, err := tx.Exec("UPDATE credentials SET user_id = $1 WHERE email = $2 AND user_id IS NULL", user.ID, newUser.Email); err != nil {
+			return 0, err
+		}
+	}
+
+	return user.ID, nil
+}
+
+// Function to create the users of an import in one transaction. Each row is
+// inserted under a savepoint, so a row that fails (e.g. an email that is
+// taken, also by an earlier row of the import) does not affect the others.
+// Returns the new ID or the error of each row. Nothing is committed if
+// dryRun is set, or if allOrNothing is set and any row failed.
+func ImportUsers(actor AuditActor, users []NewUser, allOrNothing, dryRun bool) (ids []int, rowErrors []error, committed bool, err error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer tx.Rollback()
+
+	ids = make([]int, len(users))
+	rowErrors = make([]error, len(users))
+	failed := false
+	for i, user := range users {
+		if _, err := tx.Exec("SAVEPOINT import_row"); err != nil {
+			return nil, nil, false, err
+		}
+		ids[i], rowErrors[i] = createUserTx(tx, actor, user)
+		if rowErrors[i] != nil {
+			failed = true
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT import_row"); err != nil {
+				return nil, nil, false, err
+			}
+		} else if _, err := tx.Exec("RELEASE SAVEPOINT import_row"); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	if dryRun || (allOrNothing && failed) {
+		return ids, rowErrors, false, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, false, err
+	}
+	return ids, rowErrors, true, nil
+}
+
+// Function to make the login account linked to employee record id follow a
+// change of its email from oldEmail to newEmail (nothing if it did not
+// change). Since the new address receives password resets, this fails with
+// "account email change not allowed" unless actor may manage the account,
+// and with "email already exists" if another login account uses the address.
+func followEmailChangeTx(tx *sql.Tx, actor AuditActor, id int, oldEmail, newEmail string) error {
+	if newEmail == oldEmail {
+		return nil
+	}
+	var role string
+	err := tx.QueryRow(`
+		SELECT r.name FROM credentials c
+		JOIN roles r ON r.id = c.role_id
+		WHERE c.user_id = $1 FOR UPDATE OF c`, id).Scan(&role)
+	if err == sql.ErrNoRows {
+		return nil // no login account
+	} else if err != nil {
+		return err
+	}
+	if !actor.MayManageAccount(role) {
+		return fmt.Errorf("account email change not allowed")
+	}
+	var taken bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM credentials WHERE email = $1 AND user_id IS DISTINCT FROM $2)", newEmail, id).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("email already exists")
+	}
+
+	if _, err := tx.Exec("UPDATE credentials SET email = $1 WHERE user_id = $2", newEmail, id); err != nil {
+		return err
+	}
+	// Tokens carry the old email, so sign the account out everywhere
+	return revokeAllTokenFamiliesTx(tx, oldEmail)
 }
 
-// Function to update an existing user's details
-func UpdateUser(id int, first_name, last_name, gender, location, email, phone, department, role string, salary int, join_date string, years_of_experience int) error {
-	query := "UPDATE users SET first_name = $1, last_name = $2, gender = $3, location = $4, email = $5, phone = $6, department = $7, role = $8, salary = $9, join_date = $10, years_of_experience = $11, updated_at = NOW() WHERE id = $12"
-	_, err := database.DB.Exec(query, first_name, last_name, gender, location, email, phone, department, role, salary, join_date, years_of_experience, id)
-	return err
+// Function to update an existing user's details if the record is still at
+// expectedVersion (0 for any). Fails with "email already exists" or "phone
+// already exists" if another record has the email or phone number. If the
+// email changes, the linked login account follows it and its sessions are
+// revoked. Changed fields are recorded in audit_events.
+func UpdateUser(actor AuditActor, id, expectedVersion int, first_name, last_name, gender, location, email, phone, department, role string, salary int, join_date string, years_of_experience int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockUserVersion(tx, id, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	if err := checkUserUniqueTx(tx, id, map[string]interface{}{"email": email, "phone": phone}); err != nil {
+		return err
+	}
+
+	query := "UPDATE users SET first_name = $1, last_name = $2, gender = $3, location = $4, email = $5, phone = $6, department = $7, role = $8, salary = $9, join_date = $10, years_of_experience = $11, updated_at = NOW(), version = version + 1 WHERE id = $12 RETURNING " + userColumns
+	after, err := scanUser(tx.QueryRow(query, first_name, last_name, gender, location, email, phone, department, role, salary, join_date, years_of_experience, id))
+	if err != nil {
+		return err
+	}
+
+	if err := followEmailChangeTx(tx, actor, id, before.Email, email); err != nil {
+		return err
+	}
+
+	if changes := diffUser(before, after); len(changes) > 0 {
+		if err := recordAuditEventTx(tx, actor, AuditUserUpdated, id, changes); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Function to check that no other employee record than id has the email or
+// phone number among values. Fails with "email already exists" or "phone
+// already exists".
+func checkUserUniqueTx(tx *sql.Tx, id int, values map[string]interface{}) error {
+	for _, field := range []string{"email", "phone"} {
+		value, ok := values[field]
+		if !ok {
+			continue
+		}
+		var taken bool
+		err := tx.QueryRow(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM users WHERE %s = $1 AND id <> $2)", field), value, id).Scan(&taken)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return fmt.Errorf("%s already exists", field)
+		}
+	}
+	return nil
+}
+
+// Columns of an employee record that PatchUser may change
+var patchableUserColumns = map[string]bool{
+	"first_name": true, "last_name": true, "gender": true, "location": true, "email": true, "phone": true,
+	"department": true, "role": true, "salary": true, "join_date": true, "years_of_experience": true,
+}
+
+// Function to check whether a JSON field of an employee record can be patched
+func IsPatchableUserField(field string) bool {
+	return patchableUserColumns[field]
+}
+
+const userColumns = "id, first_name, last_name, gender, location, email, phone, department, role, salary, join_date, years_of_experience, created_at, updated_at, version, deleted_at, deleted_by"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*Users, error) {
+	var user Users
+	err := row.Scan(&user.ID, &user.First_Name, &user.Last_Name, &user.Gender, &user.Location, &user.Email, &user.Phone,
+		&user.Department, &user.Role, &user.Salary, &user.Join_Date, &user.Years_of_Experience, &user.CreatedAt, &user.UpdatedAt, &user.Version,
+		&user.DeletedAt, &user.DeletedBy)
+	if err != nil {
+		return nil, err
+	}
+	user.ETag = UserETag(user.Version)
+	return &user, nil
+}
+
+// Function to lock an employee record for a change and check that it is still
+// at expectedVersion (0 accepts any version). Returns the record as it is
+// before the change, or sql.ErrNoRows (also for deleted records) or a "version mismatch" error.
+func lockUserVersion(tx *sql.Tx, id, expectedVersion int) (*Users, error) {
+	user, err := scanUser(tx.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id))
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		return nil, fmt.Errorf("version mismatch")
+	}
+	return user, nil
+}
+
+// Function to get one employee record by ID (sql.ErrNoRows if there is none
+// or it is in the trash)
+func GetUserByID(id int) (*Users, error) {
+	return scanUser(database.DB.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NULL", id))
+}
+
+// Function to change only the given columns of an employee record at
+// expectedVersion (0 for any) and return the updated record. Unknown records
+// give sql.ErrNoRows. If the email changes, the linked login account follows
+// it and its sessions are revoked. Changed fields are recorded in audit_events.
+func PatchUser(actor AuditActor, id, expectedVersion int, changes map[string]interface{}) (*Users, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockUserVersion(tx, id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkUserUniqueTx(tx, id, changes); err != nil {
+		return nil, err
+	}
+
+	// Sorted so the statement is the same for the same set of columns
+	columns := make([]string, 0, len(changes))
+	for column := range changes {
+		if !patchableUserColumns[column] {
+			return nil, fmt.Errorf("column %s cannot be patched", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	assignments := []string{"updated_at = NOW()", "version = version + 1"}
+	args := []interface{}{}
+	for i, column := range columns {
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, i+1))
+		args = append(args, changes[column])
+	}
+	query := fmt.Sprintf("UPDATE users SET %s WHERE id = $%d RETURNING %s", strings.Join(assignments, ", "), len(args)+1, userColumns)
+	user, err := scanUser(tx.QueryRow(query, append(args, id)...))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := followEmailChangeTx(tx, actor, id, before.Email, user.Email); err != nil {
+		return nil, err
+	}
+
+	if diff := diffUser(before, user); len(diff) > 0 {
+		if err := recordAuditEventTx(tx, actor, AuditUserUpdated, id, diff); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, tx.Commit()
+}
+
+// Function to move a user to the trash if the record is still at
+// expectedVersion (0 for any). The linked login account is deactivated, its
+// sessions are revoked and pending invitations are withdrawn. The record is
+// purged for good by PurgeDeletedUsers.
+func DeleteUser(actor AuditActor, id, expectedVersion int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockUserVersion(tx, id, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	if err := revokeAllTokenFamiliesTx(tx, before.Email); err != nil {
+		return err
+	}
+	// deactivated_at is set to the same NOW() as deleted_at, which is how
+	// RestoreUser tells this apart from an earlier deactivation
+	_, err = tx.Exec(`
+		UPDATE credentials SET is_active = FALSE, deactivated_at = NOW()
+		WHERE user_id = $1 AND is_active`, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE invitations SET revoked_at = NOW()
+		WHERE user_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE users SET deleted_at = NOW(), deleted_by = $2, updated_at = NOW(), version = version + 1 WHERE id = $1 RETURNING " + userColumns
+	after, err := scanUser(tx.QueryRow(query, id, actor.Actor))
+	if err != nil {
+		return err
+	}
+	if err := recordAuditEventTx(tx, actor, AuditUserDeleted, id, diffUser(before, after)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to take a user out of the trash and return the restored record.
+// A login account deactivated by the delete is activated again; one that
+// was already deactivated stays so. Gives sql.ErrNoRows if the record is not in the trash.
+func RestoreUser(actor AuditActor, id int) (*Users, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanUser(tx.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE", id))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE credentials c SET is_active = TRUE, deactivated_at = NULL
+		FROM users u
+		WHERE u.id = $1 AND c.user_id = u.id AND NOT c.is_active AND c.deactivated_at = u.deleted_at`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "UPDATE users SET deleted_at = NULL, deleted_by = NULL, updated_at = NOW(), version = version + 1 WHERE id = $1 RETURNING " + userColumns
+	user, err := scanUser(tx.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAuditEventTx(tx, actor, AuditUserRestored, id, diffUser(before, user)); err != nil {
+		return nil, err
+	}
+
+	return user, tx.Commit()
+}
+
+// Function to permanently delete users that have been in the trash longer
+// than retention. Their login accounts are removed with them (ON DELETE
+// CASCADE). No values of a purged record remain: its audit_events keep who
+// changed which fields and when, with the values redacted, and the purge is
+// recorded with the record's ID only.
+func PurgeDeletedUsers(retention time.Duration) (int, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		"DELETE FROM users WHERE deleted_at < NOW() - make_interval(secs => $1) RETURNING "+userColumns, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	var purged []*Users
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		purged = append(purged, user)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Purged records leave no personal data behind: their earlier events are
+	// redacted and the purge itself is recorded without field values
+	ids := make([]int, len(purged))
+	for i, user := range purged {
+		ids[i] = user.ID
+	}
+	if len(ids) > 0 {
+		if err := redactAuditEventsTx(tx, ids); err != nil {
+			return 0, err
+		}
+	}
+	for _, id := range ids {
+		if err := recordAuditEventTx(tx, SystemActor, AuditUserPurged, id, map[string]FieldChange{}); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(purged), tx.Commit()
 }
 
-// Function to delete a user by ID
-func DeleteUser(id int) error {
-	query := "DELETE FROM users WHERE id = $1"
-	_, err := database.DB.Exec(query, id)
-	return err
+// Function to get the email of an employee record by ID
+func GetUserEmailByID(id int) (string, error) {
+	var email string
+	err := database.DB.QueryRow("SELECT email FROM users WHERE id = $1 AND deleted_at IS NULL", id).Scan(&email)
+	return email, err
 }
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"admin-dashboard/database"
+	"database/sql"
+	"time"
+)
+
+// Login account of an employee, as included by GET /users/:id?expand=account
+type UserAccount struct {
+	Exists            bool       `json:"exists"`
+	PendingInvitation bool       `json:"pending_invitation"`
+	Role              string     `json:"role,omitempty"`
+	IsActive          bool       `json:"is_active"`
+	MFAEnabled        bool       `json:"mfa_enabled"`
+	SSOLinked         bool       `json:"sso_linked"`
+	Locked            bool       `json:"locked"`
+	LastLoginAt       *time.Time `json:"last_login_at"`
+	ActiveSessions    int        `json:"active_sessions"`
+}
+
+// Function to get the status of an employee's login account. Employees
+// without one get Exists false and whether an invitation is still open.
+func GetUserAccount(userID int) (*UserAccount, error) {
+	account := UserAccount{Exists: true}
+	err := database.DB.QueryRow(`
+		SELECT r.name, c.is_active, c.totp_enabled, c.oidc_subject IS NOT NULL,
+		       COALESCE((SELECT t.locked_until > NOW() FROM login_throttles t WHERE t.scope = $2 AND t.key = LOWER(c.email)), FALSE),
+		       (SELECT MAX(e.created_at) FROM login_events e WHERE e.email = c.email AND e.success),
+		       (SELECT COUNT(*) FROM token_families f
+		        WHERE f.email = c.email AND f.revoked_at IS NULL
+		          AND EXISTS (
+		            SELECT 1 FROM refresh_tokens rt
+		            WHERE rt.family_id = f.id AND rt.used_at IS NULL AND rt.revoked_at IS NULL AND rt.expires_at > NOW()
+		          ))
+		FROM credentials c JOIN roles r ON r.id = c.role_id
+		WHERE c.user_id = $1`, userID, ThrottleAccount).Scan(
+		&account.Role, &account.IsActive, &account.MFAEnabled, &account.SSOLinked, &account.Locked, &account.LastLoginAt, &account.ActiveSessions)
+	if err == nil {
+		return &account, nil
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	account = UserAccount{}
+	err = database.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM invitations
+			WHERE user_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		)`, userID).Scan(&account.PendingInvitation)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
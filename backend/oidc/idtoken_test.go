@@ -0,0 +1,300 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// A provider served by httptest: its discovery document, its JWKS (which a
+// test may change to rotate keys) and a token endpoint returning idToken
+type testProvider struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	keys        []jsonWebKey
+	jwksFetches int
+	idToken     string
+}
+
+func newTestProvider(t *testing.T, keys ...jsonWebKey) *testProvider {
+	t.Helper()
+	tp := &testProvider{keys: keys}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discovery{
+			Issuer:                tp.server.URL,
+			AuthorizationEndpoint: tp.server.URL + "/authorize",
+			TokenEndpoint:         tp.server.URL + "/token",
+			JWKSURI:               tp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		tp.mu.Lock()
+		defer tp.mu.Unlock()
+		tp.jwksFetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": tp.keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "authorization_code" || r.FormValue("code") != "the-code" || r.FormValue("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		tp.mu.Lock()
+		defer tp.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"id_token": tp.idToken})
+	})
+	tp.server = httptest.NewServer(mux)
+	t.Cleanup(tp.server.Close)
+	return tp
+}
+
+func (tp *testProvider) setKeys(keys ...jsonWebKey) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.keys = keys
+}
+
+func (tp *testProvider) fetches() int {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	return tp.jwksFetches
+}
+
+func (tp *testProvider) client() *Provider {
+	return &Provider{
+		IssuerURL:   tp.server.URL,
+		ClientID:    "dashboard",
+		RedirectURL: "http://localhost:3000/oidc-callback",
+		Scopes:      []string{"openid", "email"},
+		EmailClaim:  "email",
+		client:      tp.server.Client(),
+	}
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func newRSAKey(t *testing.T, kid string) (*rsa.PrivateKey, jsonWebKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, jsonWebKey{Kty: "RSA", Kid: kid, Use: "sig", N: encodeBigInt(key.N), E: encodeBigInt(big.NewInt(int64(key.E)))}
+}
+
+func newECKey(t *testing.T, kid string) (*ecdsa.PrivateKey, jsonWebKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, jsonWebKey{Kty: "EC", Kid: kid, Crv: "P-256", X: encodeBigInt(key.X), Y: encodeBigInt(key.Y)}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// Claims of a valid ID token from tp for the test client
+func validClaims(tp *testProvider) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            tp.server.URL,
+		"aud":            "dashboard",
+		"sub":            "subject-1",
+		"email":          "jo@example.com",
+		"email_verified": true,
+		"nonce":          "the-nonce",
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	rsaKey, rsaJWK := newRSAKey(t, "rsa-1")
+	ecKey, ecJWK := newECKey(t, "ec-1")
+	tp := newTestProvider(t, rsaJWK, ecJWK)
+	p := tp.client()
+	if _, err := p.discover(); err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+
+	for _, raw := range []string{
+		sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims(tp)),
+		sign(t, jwt.SigningMethodES256, "ec-1", ecKey, validClaims(tp)),
+	} {
+		identity, err := p.verifyIDToken(raw, tp.server.URL, "the-nonce")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if identity.Subject != "subject-1" || identity.Email != "jo@example.com" {
+			t.Errorf("unexpected identity %+v", identity)
+		}
+	}
+	if n := tp.fetches(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+
+	// A list audience, a custom email claim and clocks a little apart
+	claims := validClaims(tp)
+	claims["aud"] = []string{"other", "dashboard"}
+	claims["upn"] = "jo.upn@example.com"
+	claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+	claims["iat"] = time.Now().Add(30 * time.Second).Unix()
+	p.EmailClaim = "upn"
+	identity, err := p.verifyIDToken(sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims), tp.server.URL, "the-nonce")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity.Email != "jo.upn@example.com" {
+		t.Errorf("email = %q, want the upn claim", identity.Email)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	rsaKey, rsaJWK := newRSAKey(t, "rsa-1")
+	otherKey, _ := newRSAKey(t, "rsa-1")
+	tp := newTestProvider(t, rsaJWK)
+	p := tp.client()
+	if _, err := p.discover(); err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+
+	with := func(name string, value interface{}) jwt.MapClaims {
+		claims := validClaims(tp)
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"other signing key", sign(t, jwt.SigningMethodRS256, "rsa-1", otherKey, validClaims(tp))},
+		{"unknown key ID", sign(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, validClaims(tp))},
+		{"HMAC with the public key", sign(t, jwt.SigningMethodHS256, "rsa-1", []byte(rsaJWK.N), validClaims(tp))},
+		{"unsigned", sign(t, jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType, validClaims(tp))},
+		{"other issuer", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("iss", "https://evil.example.com"))},
+		{"other audience", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("aud", "other"))},
+		{"other audiences", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("aud", []string{"a", "b"}))},
+		{"expired", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("exp", time.Now().Add(-2*time.Minute).Unix()))},
+		{"no expiry", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("exp", nil))},
+		{"issued in the future", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("iat", time.Now().Add(2*time.Minute).Unix()))},
+		{"other nonce", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("nonce", "replayed"))},
+		{"no nonce", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("nonce", nil))},
+		{"no subject", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("sub", nil))},
+		{"no email", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("email", nil))},
+		{"unverified email", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, with("email_verified", false))},
+		{"not a JWT", "not.a.jwt"},
+	}
+	for _, test := range tests {
+		if identity, err := p.verifyIDToken(test.raw, tp.server.URL, "the-nonce"); err == nil {
+			t.Errorf("%s: accepted as %+v", test.name, identity)
+		}
+	}
+}
+
+func TestVerifyIDTokenKeyRotation(t *testing.T) {
+	oldKey, oldJWK := newRSAKey(t, "old")
+	newKey, newJWK := newRSAKey(t, "new")
+	tp := newTestProvider(t, oldJWK)
+	p := tp.client()
+	if _, err := p.discover(); err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+
+	if _, err := p.verifyIDToken(sign(t, jwt.SigningMethodRS256, "old", oldKey, validClaims(tp)), tp.server.URL, "the-nonce"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A token without a kid is accepted while the provider has a single key
+	if _, err := p.verifyIDToken(sign(t, jwt.SigningMethodRS256, "", oldKey, validClaims(tp)), tp.server.URL, "the-nonce"); err != nil {
+		t.Fatalf("token without kid: %v", err)
+	}
+
+	// The provider rotates its key; unknown key IDs fetch the JWKS at most once a minute
+	tp.setKeys(oldJWK, newJWK)
+	raw := sign(t, jwt.SigningMethodRS256, "new", newKey, validClaims(tp))
+	if _, err := p.verifyIDToken(raw, tp.server.URL, "the-nonce"); err == nil {
+		t.Error("key of a JWKS fetched within the refresh interval was used")
+	}
+	if n := tp.fetches(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+	p.keysFetchedAt = time.Now().Add(-jwksRefreshInterval)
+	if _, err := p.verifyIDToken(raw, tp.server.URL, "the-nonce"); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if n := tp.fetches(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+	// With several keys a token must say which one signed it
+	if _, err := p.verifyIDToken(sign(t, jwt.SigningMethodRS256, "", newKey, validClaims(tp)), tp.server.URL, "the-nonce"); err == nil {
+		t.Error("token without kid accepted with several keys")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	rsaKey, rsaJWK := newRSAKey(t, "rsa-1")
+	tp := newTestProvider(t, rsaJWK)
+	p := tp.client()
+	tp.idToken = sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims(tp))
+
+	authURL, err := p.AuthCodeURL("the-state", "the-nonce", CodeChallenge("the-verifier"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, param := range []string{"state=the-state", "nonce=the-nonce", "code_challenge_method=S256", "client_id=dashboard"} {
+		if !strings.Contains(authURL, param) {
+			t.Errorf("authorization URL %s lacks %s", authURL, param)
+		}
+	}
+
+	identity, err := p.Exchange("the-code", "the-verifier", "the-nonce")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity.Subject != "subject-1" || identity.Email != "jo@example.com" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+	if _, err := p.Exchange("the-code", "the-verifier", "another-nonce"); err == nil {
+		t.Error("ID token for another nonce was accepted")
+	}
+	if _, err := p.Exchange("wrong-code", "the-verifier", "the-nonce"); err == nil {
+		t.Error("failed token request was not reported")
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// base64url of the SHA-256 of the verifier, unpadded
+	if got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r7wW1gFWFOEjXk"); got != "bwWFMyPfdG9qreDhH2lmftFx_dFeLDalzcT1gb_j68g" {
+		t.Errorf("CodeChallenge = %q", got)
+	}
+}
@@ -0,0 +1,198 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider is an OpenID Connect identity provider used for single sign-on.
+// Any standards-compliant provider works, including local mock servers.
+type Provider struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	EmailClaim   string // ID token claim holding the user's email
+
+	client *http.Client
+
+	mu            sync.Mutex
+	config        *discovery
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+// Claims about the signed-in user taken from a verified ID token
+type Identity struct {
+	Subject string
+	Email   string
+}
+
+// Fields of the provider's /.well-known/openid-configuration document used here
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider used by the controllers, nil when single sign-on is not configured. Set by InitProvider.
+var Default *Provider
+
+// Function to configure single sign-on from the environment. Leaving
+// OIDC_ISSUER_URL empty keeps it disabled.
+func InitProvider() {
+	issuer := os.Getenv("OIDC_ISSUER_URL")
+	if issuer == "" {
+		log.Println("OIDC_ISSUER_URL is not set, single sign-on is disabled")
+		return
+	}
+
+	provider := &Provider{
+		IssuerURL:    strings.TrimSuffix(issuer, "/"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       []string{"openid", "email", "profile"},
+		EmailClaim:   "email",
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+	if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+		provider.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+	}
+	if claim := os.Getenv("OIDC_EMAIL_CLAIM"); claim != "" {
+		provider.EmailClaim = claim
+	}
+	if provider.ClientID == "" || provider.RedirectURL == "" {
+		log.Fatalf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL must be set when OIDC_ISSUER_URL is set")
+	}
+
+	Default = provider
+	log.Println("Single sign-on enabled with issuer", provider.IssuerURL)
+}
+
+// Function to derive the PKCE S256 code challenge from a code verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Function to get the provider's endpoints, fetching them on first use so the
+// backend can start before the provider is reachable
+func (p *Provider) discover() (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.config != nil {
+		return p.config, nil
+	}
+
+	var config discovery
+	if err := p.getJSON(p.IssuerURL+"/.well-known/openid-configuration", &config); err != nil {
+		return nil, fmt.Errorf("discovery failed: %v", err)
+	}
+	if strings.TrimSuffix(config.Issuer, "/") != p.IssuerURL {
+		return nil, fmt.Errorf("discovery returned issuer %q, expected %q", config.Issuer, p.IssuerURL)
+	}
+	if config.AuthorizationEndpoint == "" || config.TokenEndpoint == "" || config.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	p.config = &config
+	return p.config, nil
+}
+
+// Function to build the URL the browser is sent to for signing in
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	config, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(config.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return config.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Function to exchange an authorization code for the user's verified identity
+func (p *Provider) Exchange(code, codeVerifier, nonce string) (*Identity, error) {
+	config, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.ClientID},
+	}
+	req, err := http.NewRequest(http.MethodPost, config.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return nil, fmt.Errorf("token request failed: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return p.verifyIDToken(body.IDToken, config.Issuer, nonce)
+}
+
+// Function to GET a JSON document from the provider
+func (p *Provider) getJSON(url string, v interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,176 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"admin-dashboard/utils"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher creates and checks password hashes of one scheme. The scheme and its
+// parameters are stored in the hash itself, so hashes made with older schemes
+// or weaker parameters keep working and can be upgraded on the next login.
+type Hasher interface {
+	Recognizes(hash string) bool
+	Hash(password string) (string, error)
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash reports whether the hash was made with other parameters than the hasher's
+	NeedsRehash(hash string) bool
+}
+
+// ErrUnknownHash is returned for a stored hash no hasher recognizes
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Argon2idHasher hashes with Argon2id (RFC 9106) in the PHC string format
+// $argon2id$v=19$m=<KiB>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+const argon2idPrefix = "$argon2id$"
+
+func (h Argon2idHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Function to read the parameters, salt and key back out of an Argon2id hash
+func parseArgon2id(hash string) (params Argon2idHasher, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters %q", parts[3])
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, err
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, err
+	}
+	params.SaltLength, params.KeyLength = uint32(len(salt)), uint32(len(key))
+	return params, salt, key, nil
+}
+
+func (h Argon2idHasher) Verify(hash, password string) (bool, error) {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+	// The stored parameters are used, not the configured ones
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
+
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := parseArgon2id(hash)
+	return err != nil || params != h
+}
+
+// BcryptHasher checks the bcrypt hashes created before Argon2id was introduced
+type BcryptHasher struct {
+	Cost int
+}
+
+// bcrypt ignores everything after the first 72 bytes of a password
+const bcryptMaxBytes = 72
+
+func (h BcryptHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(hash), err
+}
+
+func (h BcryptHasher) Verify(hash, password string) (bool, error) {
+	// Longer passwords would match on their first 72 bytes alone
+	if len(password) > bcryptMaxBytes {
+		return false, nil
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < h.Cost
+}
+
+// Hasher used for new passwords, set by InitHasher
+var DefaultHasher Hasher = defaultArgon2id()
+
+// Every scheme stored hashes may use, checked in order
+var knownHashers = []Hasher{Argon2idHasher{}, BcryptHasher{}}
+
+func defaultArgon2id() Argon2idHasher {
+	return Argon2idHasher{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+}
+
+// Function to load the Argon2id parameters from the environment
+// (PASSWORD_ARGON2_MEMORY in KiB, PASSWORD_ARGON2_ITERATIONS, PASSWORD_ARGON2_PARALLELISM)
+func InitHasher() {
+	hasher := defaultArgon2id()
+	hasher.Memory = uint32(utils.GetIntEnv("PASSWORD_ARGON2_MEMORY", int(hasher.Memory)))
+	hasher.Iterations = uint32(utils.GetIntEnv("PASSWORD_ARGON2_ITERATIONS", int(hasher.Iterations)))
+	parallelism := utils.GetIntEnv("PASSWORD_ARGON2_PARALLELISM", int(hasher.Parallelism))
+	if parallelism > 255 {
+		parallelism = 255
+	}
+	hasher.Parallelism = uint8(parallelism)
+
+	log.Printf("Hashing passwords with argon2id (m=%d KiB, t=%d, p=%d)", hasher.Memory, hasher.Iterations, hasher.Parallelism)
+	DefaultHasher = hasher
+}
+
+// Function to hash a new password with DefaultHasher
+func Hash(password string) (string, error) {
+	return DefaultHasher.Hash(password)
+}
+
+// Function to check a password against a stored hash of any known scheme.
+// needsRehash is true when the password matched but the hash should be
+// replaced with a fresh one from Hash (older scheme or outdated parameters).
+func Verify(hash, password string) (match, needsRehash bool, err error) {
+	for _, hasher := range knownHashers {
+		if !hasher.Recognizes(hash) {
+			continue
+		}
+		match, err = hasher.Verify(hash, password)
+		if err != nil || !match {
+			return false, false, err
+		}
+		return true, !DefaultHasher.Recognizes(hash) || DefaultHasher.NeedsRehash(hash), nil
+	}
+	return false, false, ErrUnknownHash
+}
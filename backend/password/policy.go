@@ -0,0 +1,177 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+
+	"admin-dashboard/utils"
+)
+
+// Longest password accepted, so hashing stays cheap (PASSWORD_MAX_BYTES cannot exceed it)
+const maxPasswordBytes = 1024
+
+// Default for PASSWORD_MAX_BYTES
+const defaultMaxBytes = 128
+
+//go:embed common_passwords.txt
+var builtinBlocklist string
+
+// Policy describes the rules every new password must satisfy
+type Policy struct {
+	MinLength      int  `json:"min_length"`
+	MaxBytes       int  `json:"max_bytes"`
+	RequireUpper   bool `json:"require_upper"`
+	RequireLower   bool `json:"require_lower"`
+	RequireDigit   bool `json:"require_digit"`
+	RequireSpecial bool `json:"require_special"`
+	HistorySize    int  `json:"history_size"` // how many previous passwords may not be reused
+
+	blocklist map[string]struct{}
+}
+
+// RuleError is a single failed rule, keyed so the frontend can show its own text
+type RuleError struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every rule a password failed
+type ValidationError struct {
+	Violations []RuleError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Policy used by every flow that sets a password, set by InitPolicy
+var DefaultPolicy = &Policy{
+	MinLength:      8,
+	MaxBytes:       defaultMaxBytes,
+	RequireDigit:   true,
+	RequireSpecial: true,
+	HistorySize:    5,
+	blocklist:      parseBlocklist(builtinBlocklist),
+}
+
+// Function to load the password policy from the environment
+func InitPolicy() {
+	policy := &Policy{
+		MinLength:      utils.GetIntEnv("PASSWORD_MIN_LENGTH", 8),
+		MaxBytes:       utils.GetIntEnv("PASSWORD_MAX_BYTES", defaultMaxBytes),
+		RequireUpper:   utils.GetBoolEnv("PASSWORD_REQUIRE_UPPER", false),
+		RequireLower:   utils.GetBoolEnv("PASSWORD_REQUIRE_LOWER", false),
+		RequireDigit:   utils.GetBoolEnv("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSpecial: utils.GetBoolEnv("PASSWORD_REQUIRE_SPECIAL", true),
+		HistorySize:    utils.GetIntEnv("PASSWORD_HISTORY_SIZE", 5),
+		blocklist:      parseBlocklist(builtinBlocklist),
+	}
+	if policy.MaxBytes > maxPasswordBytes {
+		log.Printf("PASSWORD_MAX_BYTES cannot exceed %d, using %d", maxPasswordBytes, maxPasswordBytes)
+		policy.MaxBytes = maxPasswordBytes
+	}
+
+	if path := os.Getenv("PASSWORD_BLOCKLIST_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Error reading PASSWORD_BLOCKLIST_FILE %s: %v", path, err)
+		} else {
+			for word := range parseBlocklist(string(content)) {
+				policy.blocklist[word] = struct{}{}
+			}
+		}
+	}
+
+	log.Printf("Password policy loaded with %d blocked passwords", len(policy.blocklist))
+	DefaultPolicy = policy
+}
+
+// Function to check a password against every rule. previousHashes are the
+// account's current and earlier password hashes (may be empty for new accounts).
+func (p *Policy) Validate(password, email string, previousHashes []string) error {
+	var violations []RuleError
+	add := func(rule, message string) {
+		violations = append(violations, RuleError{Rule: rule, Message: message})
+	}
+
+	if len([]rune(password)) < p.MinLength {
+		add("min_length", fmt.Sprintf("Password must be at least %d characters long", p.MinLength))
+	}
+	if len(password) > p.MaxBytes {
+		add("max_length", fmt.Sprintf("Password must be at most %d bytes long", p.MaxBytes))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSpecial = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		add("uppercase", "Password must contain at least one uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		add("lowercase", "Password must contain at least one lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		add("digit", "Password must contain at least one number")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		add("special", "Password must contain at least one special character (e.g. !@#$%^&*)")
+	}
+
+	lowered := strings.ToLower(password)
+	if _, blocked := p.blocklist[lowered]; blocked {
+		add("common", "Password is too common or has appeared in a data breach")
+	}
+	if at := strings.Index(email, "@"); at > 2 && strings.Contains(lowered, strings.ToLower(email[:at])) {
+		add("contains_email", "Password must not contain your email address")
+	}
+
+	if len(password) <= p.MaxBytes {
+		for i, hash := range previousHashes {
+			if i >= p.HistorySize {
+				break
+			}
+			if match, _, err := Verify(hash, password); err == nil && match {
+				add("reused", fmt.Sprintf("Password must differ from your last %d passwords", p.HistorySize))
+				break
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// Function to parse a blocklist with one password per line ("#" starts a comment)
+func parseBlocklist(content string) map[string]struct{} {
+	blocklist := map[string]struct{}{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	return blocklist
+}
@@ -0,0 +1,156 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Function to list the rules a password fails, in the order they are reported
+func failedRules(t *testing.T, p *Policy, password, email string, previousHashes []string) []string {
+	t.Helper()
+	err := p.Validate(password, email, previousHashes)
+	if err == nil {
+		return nil
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate returned %T, want *ValidationError", err)
+	}
+	rules := make([]string, len(validationErr.Violations))
+	for i, violation := range validationErr.Violations {
+		rules[i] = violation.Rule
+	}
+	return rules
+}
+
+func TestValidateRules(t *testing.T) {
+	policy := &Policy{
+		MinLength:      8,
+		MaxBytes:       20,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSpecial: true,
+		blocklist:      parseBlocklist("Summer2024!"),
+	}
+	tests := []struct {
+		password, email string
+		want            []string
+	}{
+		{"Correct-Horse-9", "jo@example.com", nil},
+		{"Ab1!", "", []string{"min_length"}},
+		{"Ab1!Ab1!Ab1!Ab1!Ab1!x", "", []string{"max_length"}},
+		{"correct-horse-9", "", []string{"uppercase"}},
+		{"CORRECT-HORSE-9", "", []string{"lowercase"}},
+		{"Correct-Horse-x", "", []string{"digit"}},
+		{"CorrectHorse9", "", []string{"special"}},
+		{"summer2024!", "", []string{"uppercase", "common"}},
+		{"Xjordan-smith1", "Jordan-Smith@example.com", []string{"contains_email"}},
+		{"Xjo-smith1", "jo@example.com", nil}, // local parts of up to two characters are not checked
+		{"äöüÄÖÜ#1", "", nil},                 // length counts characters, not bytes
+		{"abc", "", []string{"min_length", "uppercase", "digit", "special"}},
+	}
+	for _, test := range tests {
+		if got := failedRules(t, policy, test.password, test.email, nil); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Validate(%q, %q) failed %v, want %v", test.password, test.email, got, test.want)
+		}
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	policy := &Policy{MinLength: 12, MaxBytes: 128, RequireDigit: true}
+	err := policy.Validate("short", "", nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := "Password must be at least 12 characters long; Password must contain at least one number"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+}
+
+func TestValidateHistory(t *testing.T) {
+	argon := Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	bcryptHasher := BcryptHasher{Cost: bcrypt.MinCost}
+	hash := func(h Hasher, password string) string {
+		t.Helper()
+		hash, err := h.Hash(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	history := []string{
+		hash(argon, "Newest-pass-1"),
+		hash(bcryptHasher, "Older-pass-2"),
+		hash(argon, "Oldest-pass-3"),
+	}
+
+	policy := &Policy{MinLength: 8, MaxBytes: 128, HistorySize: 2}
+	for password, want := range map[string][]string{
+		"Newest-pass-1": {"reused"},
+		"Older-pass-2":  {"reused"},
+		"Oldest-pass-3": nil, // beyond the history size
+		"Fresh-pass-4":  nil,
+	} {
+		if got := failedRules(t, policy, password, "", history); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: failed %v, want %v", password, got, want)
+		}
+	}
+
+	// New accounts have no history
+	if got := failedRules(t, policy, "Newest-pass-1", "", nil); got != nil {
+		t.Errorf("without history: failed %v", got)
+	}
+}
+
+func TestParseBlocklist(t *testing.T) {
+	got := parseBlocklist("# comment\n\n  Password1 \nletmein\n#notblocked\n")
+	want := map[string]struct{}{"password1": {}, "letmein": {}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	builtin := parseBlocklist(builtinBlocklist)
+	if _, ok := builtin["password"]; !ok {
+		t.Error("the built-in blocklist does not contain \"password\"")
+	}
+}
+
+func TestInitPolicy(t *testing.T) {
+	previous := DefaultPolicy
+	t.Cleanup(func() { DefaultPolicy = previous })
+
+	blocklistFile := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklistFile, []byte("Tr0ub4dor&3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PASSWORD_MIN_LENGTH", "10")
+	t.Setenv("PASSWORD_MAX_BYTES", "5000")
+	t.Setenv("PASSWORD_REQUIRE_UPPER", "true")
+	t.Setenv("PASSWORD_REQUIRE_SPECIAL", "false")
+	t.Setenv("PASSWORD_HISTORY_SIZE", "3")
+	t.Setenv("PASSWORD_BLOCKLIST_FILE", blocklistFile)
+	InitPolicy()
+
+	policy := DefaultPolicy
+	if policy.MinLength != 10 || policy.MaxBytes != maxPasswordBytes || !policy.RequireUpper ||
+		policy.RequireLower || !policy.RequireDigit || policy.RequireSpecial || policy.HistorySize != 3 {
+		t.Errorf("unexpected policy %+v", *policy)
+	}
+	if got := failedRules(t, policy, "Tr0ub4dor&3", "", nil); !reflect.DeepEqual(got, []string{"common"}) {
+		t.Errorf("password of PASSWORD_BLOCKLIST_FILE: failed %v", got)
+	}
+	if got := failedRules(t, policy, "Password123", "", nil); !reflect.DeepEqual(got, []string{"common"}) {
+		t.Errorf("built-in blocked password: failed %v", got)
+	}
+	if got := failedRules(t, policy, strings.Repeat("Ab1", 400), "", nil); !reflect.DeepEqual(got, []string{"max_length"}) {
+		t.Errorf("password over the byte limit: failed %v", got)
+	}
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"admin-dashboard/controllers"
 	"admin-dashboard/middleware"
+	"admin-dashboard/models"
 	"log"
 	"time"
 
@@ -11,29 +12,94 @@ import (
 )
 
 func SetupRoutes(router *gin.Engine) {
+	router.Use(middleware.RequestID())
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Authorization", "Content-Type", "If-Match", "If-None-Match", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", "X-Request-ID", "Link"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	router.POST("/login", controllers.Login)
-	router.POST("/check-email", controllers.CheckEmail)
-	router.POST("/check-email-exists", controllers.CheckEmailExists)
+	router.POST("/login/mfa", controllers.LoginMFA)
+	router.GET("/login/magic-link", controllers.GetMagicLinkConfig)
+	router.POST("/login/magic-link", controllers.RequestMagicLink)
+	router.POST("/login/magic-link/verify", controllers.MagicLinkLogin)
+	router.GET("/auth/oidc", controllers.GetOIDCConfig)
+	router.GET("/auth/oidc/login", controllers.OIDCLogin)
+	router.POST("/auth/oidc/callback", controllers.OIDCCallback)
+	router.POST("/check-email", middleware.OptionalAuthMiddleware(), controllers.CheckEmail)
+	router.POST("/check-email-exists", middleware.OptionalAuthMiddleware(), controllers.CheckEmailExists)
 	router.POST("/register", controllers.RegisterUser)
+	router.POST("/invitations/accept", controllers.AcceptInvitation)
+	router.POST("/token/refresh", controllers.RefreshToken)
+	router.POST("/password/forgot", controllers.ForgotPassword)
+	router.POST("/password/reset", controllers.ResetPassword)
+	router.GET("/password/policy", controllers.GetPasswordPolicy)
+	router.GET("/.well-known/jwks.json", controllers.GetJWKS)
 
 	authorized := router.Group("/")
 	log.Println("Setting up protected routes with AuthMiddleware")
 	authorized.Use(middleware.AuthMiddleware())
 	{
 		authorized.GET("/get-user-email", controllers.GetUserEmail)
-		authorized.PUT("/change-password", controllers.ChangePassword)
-		authorized.POST("/users", controllers.CreateUser)
-		authorized.GET("/users", controllers.GetUsers)
-		authorized.PUT("/users/:id", controllers.UpdateUser)
-		authorized.DELETE("/users/:id", controllers.DeleteUser)
+		authorized.POST("/users", middleware.RequirePermission(models.PermUsersWrite), controllers.CreateUser)
+		authorized.GET("/users", middleware.RequirePermission(models.PermUsersRead), controllers.GetUsers)
+		authorized.POST("/users/import", middleware.RequirePermission(models.PermUsersWrite), controllers.ImportUsers)
+		authorized.GET("/users/export", middleware.RequirePermission(models.PermUsersRead), controllers.ExportUsers)
+		authorized.GET("/users/trash", middleware.RequireRole(models.RoleAdmin), controllers.GetTrashedUsers)
+		authorized.GET("/users/:id", middleware.RequirePermission(models.PermUsersRead), controllers.GetUser)
+		authorized.GET("/users/:id/history", middleware.RequirePermission(models.PermUsersRead), controllers.GetUserHistory)
+		authorized.PUT("/users/:id", middleware.RequirePermission(models.PermUsersWrite), controllers.UpdateUser)
+		authorized.PATCH("/users/:id", middleware.RequirePermission(models.PermUsersWrite), controllers.PatchUser)
+		authorized.DELETE("/users/:id", middleware.RequirePermission(models.PermUsersDelete), controllers.DeleteUser)
+		authorized.GET("/roles", middleware.RequirePermission(models.PermRolesManage), controllers.GetRoles)
+		authorized.PUT("/users/:id/role", middleware.RequirePermission(models.PermRolesManage), controllers.UpdateUserRole)
+		authorized.POST("/users/:id/restore", middleware.RequireRole(models.RoleAdmin), controllers.RestoreUser)
+		authorized.POST("/users/:id/unlock", middleware.RequireRole(models.RoleAdmin), controllers.UnlockUser)
+		authorized.POST("/users/:id/invite", middleware.RequirePermission(models.PermUsersWrite), controllers.InviteUser)
+		authorized.POST("/users/:id/deactivate", middleware.RequirePermission(models.PermUsersWrite), controllers.DeactivateUser)
+		authorized.POST("/users/:id/activate", middleware.RequirePermission(models.PermUsersWrite), controllers.ActivateUser)
+		authorized.GET("/users/:id/sessions", middleware.RequireRole(models.RoleAdmin), controllers.GetUserSessions)
+		authorized.DELETE("/users/:id/sessions", middleware.RequireRole(models.RoleAdmin), controllers.RevokeUserSessions)
+		authorized.POST("/users/:id/impersonate", middleware.RequireRole(models.RoleAdmin), controllers.ImpersonateUser)
+		authorized.GET("/login-events", middleware.RequireRole(models.RoleAdmin), controllers.GetLoginEvents)
+		authorized.GET("/audit", middleware.RequireRole(models.RoleAdmin), controllers.GetAuditEvents)
+		authorized.GET("/impersonations", middleware.RequireRole(models.RoleAdmin), controllers.GetImpersonations)
+		authorized.GET("/impersonations/:id", middleware.RequireRole(models.RoleAdmin), controllers.GetImpersonation)
+		authorized.POST("/signing-keys/rotate", middleware.RequireRole(models.RoleAdmin), controllers.RotateSigningKey)
+		authorized.GET("/service-accounts", middleware.RequireRole(models.RoleAdmin), controllers.GetServiceAccounts)
+		authorized.POST("/service-accounts", middleware.RequireRole(models.RoleAdmin), controllers.CreateServiceAccount)
+		authorized.DELETE("/service-accounts/:id", middleware.RequireRole(models.RoleAdmin), controllers.DisableServiceAccount)
+		authorized.GET("/service-accounts/:id/tokens", middleware.RequireRole(models.RoleAdmin), controllers.GetServiceAccountTokens)
+		authorized.POST("/service-accounts/:id/tokens", middleware.RequireRole(models.RoleAdmin), controllers.CreateServiceAccountToken)
+		authorized.DELETE("/service-accounts/:id/tokens/:tokenId", middleware.RequireRole(models.RoleAdmin), controllers.RevokeServiceAccountToken)
+	}
+
+	// Routes that manage the signed-in account itself refuse API tokens, and
+	// apart from signing out also impersonation tokens
+	session := authorized.Group("/")
+	session.Use(middleware.RequireSession())
+	{
+		session.POST("/logout", controllers.Logout)
+		session.POST("/impersonation/end", controllers.EndImpersonation)
+	}
+	account := session.Group("/")
+	account.Use(middleware.RejectImpersonation())
+	{
+		account.GET("/mfa/totp", controllers.GetMFAStatus)
+		account.POST("/mfa/totp/enroll", controllers.EnrollTOTP)
+		account.POST("/mfa/totp/verify", controllers.VerifyTOTP)
+		account.POST("/mfa/totp/disable", controllers.DisableTOTP)
+		account.POST("/mfa/recovery-codes", controllers.RegenerateRecoveryCodes)
+		account.PUT("/change-password", controllers.ChangePassword)
+		account.GET("/me/api-tokens", controllers.GetPersonalAPITokens)
+		account.POST("/me/api-tokens", controllers.CreatePersonalAPIToken)
+		account.DELETE("/me/api-tokens/:id", controllers.RevokePersonalAPIToken)
+		account.GET("/me/sessions", controllers.GetMySessions)
+		account.DELETE("/me/sessions/:id", controllers.RevokeMySession)
+		account.GET("/me/login-history", controllers.GetMyLoginHistory)
 	}
 }
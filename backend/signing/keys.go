@@ -0,0 +1,297 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"admin-dashboard/models"
+	"admin-dashboard/utils"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// How often the key set is re-read so keys rotated by another instance are picked up
+const reloadInterval = time.Minute
+
+// A key loaded from the signing_keys table
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// JWK is a public key as published in /.well-known/jwks.json
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+var (
+	mu       sync.RWMutex
+	current  *signingKey            // signs new tokens
+	keys     map[string]*signingKey // verify tokens, by kid
+	loadedAt time.Time
+)
+
+// Function to get the issuer put in and required of every token (JWT_ISSUER)
+func Issuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "admin-dashboard"
+}
+
+// Function to create the first key if needed and load the key set. Call once at startup.
+func Init() {
+	if err := Rotate(); err != nil {
+		log.Fatalf("Error setting up JWT signing keys: %v", err)
+	}
+	log.Printf("JWT signing key %s (%s) loaded", current.kid, current.method.Alg())
+}
+
+// Function to run the scheduled rotation and reload the key set. Safe to call
+// from every instance; rotation only happens when it is due.
+//
+// JWT_KEY_ROTATION_INTERVAL (default 720h) is how long a key signs tokens,
+// JWT_KEY_PREPUBLISH (default 1h) is how long a new key is published before
+// it is used, and JWT_KEY_RETENTION (default 168h) is how long a replaced key
+// still verifies tokens; it must exceed the longest token lifetime (ACCESS_TOKEN_TTL, INVITATION_TTL).
+func Rotate() error {
+	return rotate(false, true)
+}
+
+// Function to switch to a new key immediately after a key was exposed. The
+// replaced keys are deleted, so tokens they signed stop verifying at once
+// (on other instances when they next reload the key set) and clients must
+// refresh them.
+func RotateNow() error {
+	return rotate(true, false)
+}
+
+// Function to switch to a new key immediately without an exposure, e.g.
+// after changing JWT_SIGNING_ALG. Tokens signed with the replaced key remain
+// valid for JWT_KEY_RETENTION.
+func RotateNowGraceful() error {
+	return rotate(true, true)
+}
+
+func rotate(force, keepReplaced bool) error {
+	interval := utils.GetDurationEnv("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour)
+	prepublish := utils.GetDurationEnv("JWT_KEY_PREPUBLISH", time.Hour)
+	retention := time.Duration(0)
+	if keepReplaced {
+		retention = utils.GetDurationEnv("JWT_KEY_RETENTION", 7*24*time.Hour)
+	}
+	if prepublish >= interval {
+		prepublish = interval / 2
+	}
+
+	if err := models.RotateSigningKeys(generateKey, interval, prepublish, retention, force); err != nil {
+		return err
+	}
+	return reload()
+}
+
+// Function to create a key for the algorithm in JWT_SIGNING_ALG (RS256 or EdDSA)
+func generateKey() (*models.SigningKey, error) {
+	kid, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	var private interface{}
+	algorithm := os.Getenv("JWT_SIGNING_ALG")
+	switch algorithm {
+	case "", jwt.SigningMethodRS256.Alg():
+		algorithm = jwt.SigningMethodRS256.Alg()
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case SigningMethodEdDSA.Alg():
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported JWT_SIGNING_ALG %q", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SigningKey{
+		KID:        kid,
+		Algorithm:  algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}, nil
+}
+
+// Function to read the key set from the database
+func reload() error {
+	rows, err := models.GetSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	loaded := map[string]*signingKey{}
+	var signer *signingKey
+	for _, row := range rows {
+		key, err := parseKey(row)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", row.KID, err)
+			continue
+		}
+		loaded[key.kid] = key
+		if signer == nil && row.Active {
+			signer = key // rows are ordered newest activation first
+		}
+	}
+	if signer == nil {
+		return errors.New("no active signing key")
+	}
+
+	mu.Lock()
+	current, keys, loadedAt = signer, loaded, time.Now()
+	mu.Unlock()
+	return nil
+}
+
+func parseKey(row models.SigningKey) (*signingKey, error) {
+	block, _ := pem.Decode([]byte(row.PrivateKey))
+	if block == nil {
+		return nil, errors.New("invalid PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: row.KID}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, private, &private.PublicKey
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = SigningMethodEdDSA, private, private.Public()
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	if key.method.Alg() != row.Algorithm {
+		return nil, fmt.Errorf("key does not match algorithm %s", row.Algorithm)
+	}
+	return key, nil
+}
+
+// Function to get the current key set, re-reading it when it is stale. A
+// failed reload keeps the previous set so a database hiccup does not log everyone out.
+func keySet() (*signingKey, map[string]*signingKey, error) {
+	mu.RLock()
+	signer, verifiers, age := current, keys, time.Since(loadedAt)
+	mu.RUnlock()
+
+	if age > reloadInterval {
+		if err := reload(); err != nil {
+			log.Printf("Error reloading signing keys: %v", err)
+			mu.Lock()
+			loadedAt = time.Now() // retry after another interval
+			mu.Unlock()
+		} else {
+			mu.RLock()
+			signer, verifiers = current, keys
+			mu.RUnlock()
+		}
+	}
+	if signer == nil {
+		return nil, nil, errors.New("signing keys not loaded")
+	}
+	return signer, verifiers, nil
+}
+
+// Function to sign claims with the current key. The token header names the
+// key ("kid") and the issuer claim is added.
+func Sign(claims jwt.MapClaims) (string, error) {
+	signer, _, err := keySet()
+	if err != nil {
+		return "", err
+	}
+
+	claims["iss"] = Issuer()
+	token := jwt.NewWithClaims(signer.method, claims)
+	token.Header["kid"] = signer.kid
+	return token.SignedString(signer.private)
+}
+
+// Function to verify a token signed by any published key and return its claims
+func Parse(tokenString string) (jwt.MapClaims, error) {
+	_, verifiers, err := keySet()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := verifiers[kid]
+		if !ok {
+			return nil, jwt.NewValidationError("Unknown signing key", jwt.ValidationErrorSignatureInvalid)
+		}
+		// The key decides the algorithm, never the token header
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, jwt.NewValidationError("Invalid signing method", jwt.ValidationErrorSignatureInvalid)
+		}
+		return key.public, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyIssuer(Issuer(), true) {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// Function to list the public keys for /.well-known/jwks.json, including keys
+// that are published ahead of use and replaced keys that still verify tokens
+func JWKS() ([]JWK, error) {
+	_, verifiers, err := keySet()
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := []JWK{}
+	for kid, key := range verifiers {
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		jwks = append(jwks, jwk)
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks, nil
+}
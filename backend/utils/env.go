@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Function to read a duration such as "15m" from the environment with a fallback
+func GetDurationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s value %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// Function to read a positive integer from the environment with a fallback
+func GetIntEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s value %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// Function to read a boolean such as "true" from the environment with a fallback
+func GetBoolEnv(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
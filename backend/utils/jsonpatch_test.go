@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, text string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatalf("invalid JSON %s: %v", text, err)
+	}
+	return value
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c","d":"e"}}`, `{"a":{"d":null,"f":"g"}}`, `{"a":{"b":"c","f":"g"}}`},
+		{`{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`"text"`, `{"a":"b"}`, `{"a":"b"}`},
+	}
+	for _, test := range tests {
+		got := MergePatch(decodeJSON(t, test.target), decodeJSON(t, test.patch))
+		if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("MergePatch(%s, %s) = %v, want %v", test.target, test.patch, got, want)
+		}
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+	}{
+		{"add member", `{"a":1}`, `[{"op":"add","path":"/b","value":2}]`, `{"a":1,"b":2}`},
+		{"add to array", `{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2,3]}`},
+		{"append to array", `{"a":[1]}`, `[{"op":"add","path":"/a/-","value":2}]`, `{"a":[1,2]}`},
+		{"remove", `{"a":1,"b":2}`, `[{"op":"remove","path":"/b"}]`, `{"a":1}`},
+		{"remove from array", `{"a":[1,2,3]}`, `[{"op":"remove","path":"/a/0"}]`, `{"a":[2,3]}`},
+		{"replace", `{"a":1}`, `[{"op":"replace","path":"/a","value":"x"}]`, `{"a":"x"}`},
+		{"replace document", `{"a":1}`, `[{"op":"add","path":"","value":[1]}]`, `[1]`},
+		{"move", `{"a":{"b":1},"c":{}}`, `[{"op":"move","from":"/a/b","path":"/c/d"}]`, `{"a":{},"c":{"d":1}}`},
+		{"copy", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":1},"c":{"b":1}}`},
+		{"test then replace", `{"a":[1,{"b":null}]}`, `[{"op":"test","path":"/a","value":[1,{"b":null}]},{"op":"replace","path":"/a","value":0}]`, `{"a":0}`},
+		{"escaped pointer", `{"a/b":1,"c~d":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"replace","path":"/c~0d","value":3}]`, `{"c~d":3}`},
+	}
+	for _, test := range tests {
+		var operations []PatchOperation
+		if err := json.Unmarshal([]byte(test.patch), &operations); err != nil {
+			t.Fatalf("%s: invalid patch: %v", test.name, err)
+		}
+		got, err := ApplyJSONPatch(decodeJSON(t, test.doc), operations)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, want)
+		}
+	}
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+	tests := []struct {
+		name, doc, patch string
+	}{
+		{"unknown operation", `{}`, `[{"op":"merge","path":"/a","value":1}]`},
+		{"missing value", `{}`, `[{"op":"add","path":"/a"}]`},
+		{"path without slash", `{}`, `[{"op":"add","path":"a","value":1}]`},
+		{"missing parent", `{}`, `[{"op":"add","path":"/a/b","value":1}]`},
+		{"remove missing member", `{}`, `[{"op":"remove","path":"/a"}]`},
+		{"replace missing member", `{}`, `[{"op":"replace","path":"/a","value":1}]`},
+		{"remove document", `{}`, `[{"op":"remove","path":""}]`},
+		{"index out of range", `{"a":[1]}`, `[{"op":"add","path":"/a/2","value":1}]`},
+		{"index with leading zero", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/01"}]`},
+		{"end index outside add", `{"a":[1]}`, `[{"op":"remove","path":"/a/-"}]`},
+		{"move into itself", `{"a":{"b":{}}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`},
+		{"failed test", `{"a":1}`, `[{"op":"test","path":"/a","value":"1"}]`},
+	}
+	for _, test := range tests {
+		var operations []PatchOperation
+		if err := json.Unmarshal([]byte(test.patch), &operations); err != nil {
+			t.Fatalf("%s: invalid patch: %v", test.name, err)
+		}
+		if _, err := ApplyJSONPatch(decodeJSON(t, test.doc), operations); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestApplyJSONPatchCopyIsIndependent(t *testing.T) {
+	doc := decodeJSON(t, `{"a":{"b":1}}`)
+	operations := []PatchOperation{
+		{Op: "copy", From: "/a", Path: "/c"},
+		{Op: "replace", Path: "/c/b", Value: json.RawMessage(`2`)},
+	}
+	got, err := ApplyJSONPatch(doc, operations)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := decodeJSON(t, `{"a":{"b":1},"c":{"b":2}}`); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Secret of the RFC 6238 test vectors ("12345678901234567890")
+var rfcTOTPSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestValidateTOTPVectors(t *testing.T) {
+	// RFC 6238 appendix B (SHA-1), last six of the eight digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, test := range tests {
+		step, ok := ValidateTOTP(rfcTOTPSecret, test.code, time.Unix(test.unix, 0))
+		if !ok {
+			t.Errorf("code %s at %d was refused", test.code, test.unix)
+		} else if step != test.unix/totpPeriod {
+			t.Errorf("code %s at %d matched step %d, want %d", test.code, test.unix, step, test.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	key, _ := totpEncoding.DecodeString(rfcTOTPSecret)
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+
+	for _, offset := range []int64{-1, 1} {
+		if step, ok := ValidateTOTP(rfcTOTPSecret, totpCode(key, current+offset), now); !ok || step != current+offset {
+			t.Errorf("code of step %+d: got step %d, %v", offset, step, ok)
+		}
+	}
+	for _, offset := range []int64{-2, 2} {
+		if _, ok := ValidateTOTP(rfcTOTPSecret, totpCode(key, current+offset), now); ok {
+			t.Errorf("code of step %+d was accepted", offset)
+		}
+	}
+}
+
+func TestValidateTOTPInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	if _, ok := ValidateTOTP(rfcTOTPSecret, "287 082", now); !ok {
+		t.Error("code with a space was refused")
+	}
+	if _, ok := ValidateTOTP(strings.ToLower(rfcTOTPSecret), "287082", now); !ok {
+		t.Error("lower-case secret was refused")
+	}
+	for _, code := range []string{"", "28708", "2870821", "287083"} {
+		if _, ok := ValidateTOTP(rfcTOTPSecret, code, now); ok {
+			t.Errorf("code %q was accepted", code)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32!", "287082", now); ok {
+		t.Error("invalid secret was accepted")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Errorf("secret %q does not decode to 20 bytes", secret)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Admin Dashboard", "jo@example.com", "ABC"))
+	if err != nil {
+		t.Fatalf("invalid URI: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Admin Dashboard:jo@example.com" {
+		t.Errorf("unexpected URI %s", uri)
+	}
+	query := uri.Query()
+	for name, want := range map[string]string{"secret": "ABC", "issuer": "Admin Dashboard", "digits": "6", "period": "30"} {
+		if got := query.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q was generated twice", code)
+		}
+		seen[code] = true
+		if got := NormalizeRecoveryCode(code); got != code {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q", code, got)
+		}
+	}
+	if len(codes) != 10 {
+		t.Errorf("got %d codes, want 10", len(codes))
+	}
+
+	for input, want := range map[string]string{
+		" ABCDE-FGHIJ ": "abcde-fghij",
+		"abcdefghij":    "abcde-fghij",
+		"abcde fghij":   "abcde-fghij",
+	} {
+		if got := NormalizeRecoveryCode(input); got != want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Largest uncompressed part of a workbook that is read, against zip bombs
+const maxXLSXPartBytes = 64 << 20
+
+// Number of columns of a worksheet (A to XFD)
+const maxXLSXColumns = 16384
+
+// Elements of the SpreadsheetML parts (ECMA-376) needed to read cell values
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var text strings.Builder
+	for _, run := range t.Runs {
+		text.WriteString(run.Text)
+	}
+	return text.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Style  int      `xml:"s,attr"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+type xlsxRow struct {
+	Number int        `xml:"r,attr"` // 1-based; rows without values may be left out
+	Cells  []xlsxCell `xml:"c"`
+}
+
+// Function to read the rows of the first worksheet of an XLSX workbook as
+// text. Dates are given as YYYY-MM-DD, other numbers as stored; formulas
+// are read as their cached result. The first row is the header row: values
+// right of its last column are refused, as are sheets with more than maxRows rows.
+func ReadXLSX(r io.ReaderAt, size int64, maxRows int) ([][]string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an XLSX file")
+	}
+	parts := map[string]*zip.File{}
+	for _, file := range archive.File {
+		parts[file.Name] = file
+	}
+
+	var workbook xlsxWorkbook
+	if err := decodeXLSXPart(parts, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("workbook has no sheets")
+	}
+	var relationships xlsxRelationships
+	if err := decodeXLSXPart(parts, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return nil, err
+	}
+	sheetPath := ""
+	for _, rel := range relationships.Relationships {
+		if rel.ID == workbook.Sheets[0].RelID {
+			if strings.HasPrefix(rel.Target, "/") {
+				sheetPath = strings.TrimPrefix(rel.Target, "/")
+			} else {
+				sheetPath = path.Join("xl", rel.Target)
+			}
+		}
+	}
+	if sheetPath == "" {
+		return nil, fmt.Errorf("first sheet not found")
+	}
+
+	// Shared strings and styles are optional parts
+	var sharedStrings xlsxSharedStrings
+	if _, ok := parts["xl/sharedStrings.xml"]; ok {
+		if err := decodeXLSXPart(parts, "xl/sharedStrings.xml", &sharedStrings); err != nil {
+			return nil, err
+		}
+	}
+	var styles xlsxStyles
+	if _, ok := parts["xl/styles.xml"]; ok {
+		if err := decodeXLSXPart(parts, "xl/styles.xml", &styles); err != nil {
+			return nil, err
+		}
+	}
+	dateStyles := xlsxDateStyles(styles)
+
+	sheet, ok := parts[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("missing part %s", sheetPath)
+	}
+	reader, err := sheet.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// Rows are decoded one at a time so large sheets are not held as XML
+	rows := [][]string{}
+	decoder := xml.NewDecoder(io.LimitReader(reader, maxXLSXPartBytes))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid worksheet: %v", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row xlsxRow
+		if err := decoder.DecodeElement(&row, &start); err != nil {
+			return nil, fmt.Errorf("invalid worksheet: %v", err)
+		}
+
+		if row.Number > maxRows || len(rows) >= maxRows {
+			return nil, fmt.Errorf("more than %d rows", maxRows)
+		}
+		for row.Number > len(rows)+1 {
+			rows = append(rows, []string{})
+		}
+		// Rows are no wider than the header row, so a far-off cell reference
+		// cannot make every row allocate thousands of columns
+		width := maxXLSXColumns
+		if len(rows) > 0 {
+			width = len(rows[0])
+		}
+		values := []string{}
+		column := -1
+		for _, cell := range row.Cells {
+			column++ // a cell without a reference follows the previous one
+			if cell.Ref != "" {
+				if column, err = xlsxColumnIndex(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+			if column >= width {
+				if cell.Value == "" && cell.Inline.String() == "" {
+					continue // formatted but empty
+				}
+				return nil, fmt.Errorf("row %d has values right of the header row", len(rows)+1)
+			}
+			for len(values) <= column {
+				values = append(values, "")
+			}
+			values[column] = xlsxCellValue(cell, sharedStrings, dateStyles, workbook.Properties.Date1904)
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+func decodeXLSXPart(parts map[string]*zip.File, name string, v interface{}) error {
+	file, ok := parts[name]
+	if !ok {
+		return fmt.Errorf("missing part %s", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := xml.NewDecoder(io.LimitReader(reader, maxXLSXPartBytes)).Decode(v); err != nil {
+		return fmt.Errorf("invalid part %s: %v", name, err)
+	}
+	return nil
+}
+
+// Function to find the cell styles that show numbers as dates: the built-in
+// date formats and custom formats with day, month or year codes
+func xlsxDateStyles(styles xlsxStyles) map[int]bool {
+	dateFormats := map[int]bool{}
+	for id := 14; id <= 22; id++ {
+		dateFormats[id] = true
+	}
+	for _, id := range []int{45, 46, 47} {
+		dateFormats[id] = true
+	}
+	for _, format := range styles.NumFmts {
+		dateFormats[format.ID] = isDateFormatCode(format.Code)
+	}
+
+	dateStyles := map[int]bool{}
+	for i, xf := range styles.CellXfs {
+		dateStyles[i] = dateFormats[xf.NumFmtID]
+	}
+	return dateStyles
+}
+
+// Function to check whether a number format code shows a date, ignoring
+// quoted text, escaped characters and colours such as [Red]
+func isDateFormatCode(code string) bool {
+	inQuotes, inBrackets := false, false
+	for i := 0; i < len(code); i++ {
+		ch := code[i]
+		switch {
+		case ch == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case ch == '\\':
+			i++
+		case ch == '[':
+			inBrackets = true
+		case ch == ']':
+			inBrackets = false
+		case inBrackets:
+		case strings.ContainsRune("dDmMyY", rune(ch)):
+			return true
+		}
+	}
+	return false
+}
+
+// Function to turn a cell reference such as "AB12" into a zero-based column index
+func xlsxColumnIndex(ref string) (int, error) {
+	column := 0
+	for i, ch := range ref {
+		if ch >= 'A' && ch <= 'Z' {
+			if column = column*26 + int(ch-'A'+1); column > maxXLSXColumns {
+				break
+			}
+			continue
+		}
+		if i == 0 {
+			break
+		}
+		return column - 1, nil
+	}
+	return 0, fmt.Errorf("invalid cell reference %q", ref)
+}
+
+func xlsxCellValue(cell xlsxCell, sharedStrings xlsxSharedStrings, dateStyles map[int]bool, date1904 bool) string {
+	switch cell.Type {
+	case "s":
+		index, err := strconv.Atoi(cell.Value)
+		if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+			return ""
+		}
+		return sharedStrings.Items[index].String()
+	case "inlineStr":
+		return cell.Inline.String()
+	case "b":
+		if cell.Value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "str", "e":
+		return cell.Value
+	}
+
+	if cell.Value != "" && dateStyles[cell.Style] {
+		if serial, err := strconv.ParseFloat(cell.Value, 64); err == nil {
+			return xlsxDate(serial, date1904).Format("2006-01-02")
+		}
+	}
+	return cell.Value
+}
+
+// Function to convert a spreadsheet date serial number to a date. The 1900
+// date system counts the non-existent 29 February 1900, hence the 30 December 1899 epoch.
+func xlsxDate(serial float64, date1904 bool) time.Time {
+	epoch := time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		epoch = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 24 * 60 * 60)
+	return epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+}
+
+// Parts of a workbook with one sheet, written before the sheet's rows. Style
+// 1 shows dates (built-in format 14), style 2 dates with times (format 22).
+var xlsxStaticParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="14" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="22" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs></styleSheet>`},
+}
+
+// XLSXWriter writes a workbook with one sheet row by row, so a sheet of any
+// size is never held in memory
+type XLSXWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	rows    int
+}
+
+// Function to start a workbook on w. Rows are added with WriteRow; Close completes the file.
+func NewXLSXWriter(w io.Writer) (*XLSXWriter, error) {
+	archive := zip.NewWriter(w)
+	for _, part := range xlsxStaticParts {
+		writer, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(writer, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+	return &XLSXWriter{archive: archive, sheet: sheet}, nil
+}
+
+// Function to add a row. Values may be strings, ints, float64s or times;
+// times at midnight are shown as dates. nil leaves the cell empty.
+func (w *XLSXWriter) WriteRow(values []interface{}) error {
+	w.rows++
+	var row strings.Builder
+	fmt.Fprintf(&row, `<row r="%d">`, w.rows)
+	for i, value := range values {
+		ref := xlsxColumnName(i) + strconv.Itoa(w.rows)
+		switch v := value.(type) {
+		case nil:
+		case string:
+			fmt.Fprintf(&row, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			if err := xml.EscapeText(&row, []byte(v)); err != nil {
+				return err
+			}
+			row.WriteString(`</t></is></c>`)
+		case int:
+			fmt.Fprintf(&row, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case float64:
+			fmt.Fprintf(&row, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+		case time.Time:
+			style := 1
+			if v.Hour() != 0 || v.Minute() != 0 || v.Second() != 0 {
+				style = 2
+			}
+			fmt.Fprintf(&row, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, strconv.FormatFloat(xlsxSerial(v), 'f', -1, 64))
+		default:
+			return fmt.Errorf("unsupported cell value %T", value)
+		}
+	}
+	row.WriteString(`</row>`)
+	_, err := io.WriteString(w.sheet, row.String())
+	return err
+}
+
+// Function to finish the workbook. It does not close the underlying writer.
+func (w *XLSXWriter) Close() error {
+	if _, err := io.WriteString(w.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return w.archive.Close()
+}
+
+// Function to turn a zero-based column index into its letters ("A", ..., "Z", "AA", ...)
+func xlsxColumnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// Function to convert a time to a spreadsheet date serial number (1900 date
+// system), the inverse of xlsxDate. The clock time is kept as written.
+func xlsxSerial(t time.Time) float64 {
+	epoch := time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(epoch).Hours() / 24
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function to build a workbook from the parts the writer uses, with the given
+// sheet data and extra parts (e.g. shared strings)
+func buildXLSX(t *testing.T, sheetData string, extra map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	parts := map[string]string{
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	}
+	for _, part := range xlsxStaticParts {
+		parts[part.name] = part.content
+	}
+	for name, content := range extra {
+		parts[name] = content
+	}
+	for name, content := range parts {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readXLSXBytes(data []byte, maxRows int) ([][]string, error) {
+	return ReadXLSX(bytes.NewReader(data), int64(len(data)), maxRows)
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewXLSXWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]interface{}{
+		{"name", "count", "ratio", "joined", "seen_at"},
+		{"Jo <&> \"Doe\"", 3, 1.5, time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 2, 10, 30, 0, 0, time.UTC)},
+		{" spaced ", nil, -2.25, nil, nil},
+	}
+	for _, row := range rows {
+		if err := writer.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readXLSXBytes(buf.Bytes(), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := [][]string{
+		{"name", "count", "ratio", "joined", "seen_at"},
+		{"Jo <&> \"Doe\"", "3", "1.5", "2023-03-15", "2024-01-02"},
+		{" spaced ", "", "-2.25"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWriteRowUnsupportedValue(t *testing.T) {
+	writer, err := NewXLSXWriter(&bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteRow([]interface{}{true}); err == nil {
+		t.Error("expected an error for a bool")
+	}
+}
+
+func TestReadXLSXCells(t *testing.T) {
+	sharedStrings := map[string]string{
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>first</t></si><si><r><t>rich </t></r><r><t>text</t></r></si></sst>`,
+	}
+	data := buildXLSX(t, `
+		<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="b"><v>1</v></c><c r="D1" t="str"><v>formula</v></c></row>
+		<row r="3"><c r="B3" s="1"><v>45000</v></c><c t="inlineStr"><is><t>no ref</t></is></c><c r="A3" t="s"><v>9</v></c></row>`, sharedStrings)
+
+	got, err := readXLSXBytes(data, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := [][]string{
+		{"first", "rich text", "TRUE", "formula"},
+		{},
+		{"", "2023-03-15", "no ref"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadXLSXHeaderWidth(t *testing.T) {
+	// Formatted but empty cells right of the header are ignored
+	data := buildXLSX(t, `
+		<row r="1"><c r="A1" t="inlineStr"><is><t>a</t></is></c><c r="B1" t="inlineStr"><is><t>b</t></is></c></row>
+		<row r="2"><c r="A2"><v>1</v></c><c r="XFD2" s="1"/></row>`, nil)
+	got, err := readXLSXBytes(data, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := [][]string{{"a", "b"}, {"1"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Values right of the header are refused
+	for _, cell := range []string{`<c r="C2"><v>1</v></c>`, `<c r="XFD2" t="inlineStr"><is><t>x</t></is></c>`} {
+		data := buildXLSX(t, `
+			<row r="1"><c r="A1" t="inlineStr"><is><t>a</t></is></c><c r="B1" t="inlineStr"><is><t>b</t></is></c></row>
+			<row r="2">`+cell+`</row>`, nil)
+		if _, err := readXLSXBytes(data, 10); err == nil || !strings.Contains(err.Error(), "right of the header row") {
+			t.Errorf("%s: got error %v", cell, err)
+		}
+	}
+}
+
+func TestReadXLSXLimits(t *testing.T) {
+	data := buildXLSX(t, `<row r="1"><c r="A1"><v>1</v></c></row><row r="2"><c r="A2"><v>2</v></c></row><row r="3"><c r="A3"><v>3</v></c></row>`, nil)
+	if _, err := readXLSXBytes(data, 2); err == nil {
+		t.Error("expected an error for too many rows")
+	}
+	data = buildXLSX(t, `<row r="1"><c r="A1"><v>1</v></c></row><row r="1000000"><c r="A1000000"><v>2</v></c></row>`, nil)
+	if _, err := readXLSXBytes(data, 10); err == nil {
+		t.Error("expected an error for a row number past the limit")
+	}
+	data = buildXLSX(t, `<row r="1"><c r="1A"><v>1</v></c></row>`, nil)
+	if _, err := readXLSXBytes(data, 10); err == nil {
+		t.Error("expected an error for an invalid cell reference")
+	}
+	if _, err := readXLSXBytes([]byte("name,email\n"), 10); err == nil {
+		t.Error("expected an error for a file that is not a workbook")
+	}
+}
+
+func TestXLSXColumnIndex(t *testing.T) {
+	for ref, want := range map[string]int{"A1": 0, "Z9": 25, "AA10": 26, "AB12": 27, "XFD1": maxXLSXColumns - 1} {
+		if got, err := xlsxColumnIndex(ref); err != nil || got != want {
+			t.Errorf("xlsxColumnIndex(%q) = %d, %v, want %d", ref, got, err, want)
+		}
+		if name := xlsxColumnName(want); name != strings.TrimRight(ref, "0123456789") {
+			t.Errorf("xlsxColumnName(%d) = %q", want, name)
+		}
+	}
+	for _, ref := range []string{"", "1", "A", "a1", "XFE1", "AAAAA1"} {
+		if _, err := xlsxColumnIndex(ref); err == nil {
+			t.Errorf("xlsxColumnIndex(%q) did not fail", ref)
+		}
+	}
+}
+
+func TestIsDateFormatCode(t *testing.T) {
+	for code, want := range map[string]bool{
+		"yyyy-mm-dd":       true,
+		"d/m/yy h:mm":      true,
+		"0.00":             false,
+		`"day "0`:          false,
+		`\d0`:              false,
+		"[Red]0.00":        false,
+		"[$-409]mmmm yyyy": true,
+	} {
+		if got := isDateFormatCode(code); got != want {
+			t.Errorf("isDateFormatCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestXLSXDate(t *testing.T) {
+	tests := []struct {
+		serial   float64
+		date1904 bool
+		want     time.Time
+	}{
+		{1, false, time.Date(1899, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{45000, false, time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{45000.5, false, time.Date(2023, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{0, true, time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if got := xlsxDate(test.serial, test.date1904); !got.Equal(test.want) {
+			t.Errorf("xlsxDate(%v, %v) = %v, want %v", test.serial, test.date1904, got, test.want)
+		}
+		if !test.date1904 && xlsxSerial(test.want) != test.serial {
+			t.Errorf("xlsxSerial(%v) = %v, want %v", test.want, xlsxSerial(test.want), test.serial)
+		}
+	}
+}
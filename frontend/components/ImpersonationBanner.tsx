@@ -0,0 +1,38 @@
+// components/ImpersonationBanner.tsx
+import { useEffect, useState } from "react";
+import { useRouter } from "next/router";
+import { endImpersonation } from "../utils/auth";
+
+// Shown on every dashboard while an admin is viewing it as another user
+const ImpersonationBanner = () => {
+  const router = useRouter();
+  const [impersonatedBy, setImpersonatedBy] = useState<string | null>(null);
+  const [email, setEmail] = useState<string | null>(null);
+
+  useEffect(() => {
+    setImpersonatedBy(localStorage.getItem("impersonated_by"));
+    setEmail(localStorage.getItem("email"));
+  }, []);
+
+  if (!impersonatedBy) {
+    return null;
+  }
+
+  const handleEnd = async () => {
+    await endImpersonation();
+    router.push("/dashboard");
+  };
+
+  return (
+    <div className="w-full bg-amber-400 text-black px-4 py-2 mb-4 rounded flex justify-between items-center">
+      <span>
+        Viewing as <strong>{email}</strong> (impersonated by {impersonatedBy}). Every action is recorded.
+      </span>
+      <button onClick={handleEnd} className="px-3 py-1 bg-black text-white rounded hover:bg-gray-800">
+        End impersonation
+      </button>
+    </div>
+  );
+};
+
+export default ImpersonationBanner;
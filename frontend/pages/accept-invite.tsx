@@ -0,0 +1,72 @@
+import { useState, FormEvent } from "react";
+import axios from "axios";
+import { useRouter } from "next/router";
+import { apiErrorMessage } from "../utils/auth";
+import "../src/app/globals.css";
+
+// Landing page for emailed invitations: the invitee chooses a password to create their account
+const AcceptInvite = () => {
+  const [password, setPassword] = useState("");
+  const [confirmPassword, setConfirmPassword] = useState("");
+  const [error, setError] = useState("");
+  const router = useRouter();
+  const token = typeof router.query.token === "string" ? router.query.token : "";
+
+  const handleSubmit = async (e: FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    setError("");
+
+    if (password !== confirmPassword) {
+      setError("Passwords do not match.");
+      return;
+    }
+
+    try {
+      await axios.post("http://localhost:8080/invitations/accept", { token, password });
+      alert("✅ Your account is ready! Please log in.");
+      router.push("/login");
+    } catch (err) {
+      if (axios.isAxiosError(err) && err.response) {
+        setError(apiErrorMessage(err.response.data, "An error occurred"));
+      } else {
+        setError("An unexpected error occurred");
+      }
+    }
+  };
+
+  const inputClass =
+    "w-full px-4 py-2 border border-gray-300 rounded-lg text-black bg-gray-100 focus:outline-none focus:ring-2 focus:ring-blue-500";
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-sky-100 to-blue-200 flex items-center justify-center p-6">
+      <div className="w-full max-w-md bg-white rounded-2xl shadow-lg p-8">
+        <h1 className="text-3xl font-bold text-center text-blue-800 mb-6">👋 Accept Invitation</h1>
+
+        {!token && <div className="bg-red-100 border border-red-300 text-red-700 px-4 py-2 rounded mb-4">This invitation link is incomplete.</div>}
+        {error && <div className="bg-red-100 border border-red-300 text-red-700 px-4 py-2 rounded mb-4">{error}</div>}
+
+        <form onSubmit={handleSubmit} className="space-y-5">
+          <div>
+            <label className="block text-gray-700 font-medium mb-1">Password</label>
+            <input type="password" value={password} onChange={(e) => setPassword(e.target.value)} className={inputClass} required />
+          </div>
+
+          <div>
+            <label className="block text-gray-700 font-medium mb-1">Confirm Password</label>
+            <input type="password" value={confirmPassword} onChange={(e) => setConfirmPassword(e.target.value)} className={inputClass} required />
+          </div>
+
+          <button
+            type="submit"
+            disabled={!token}
+            className="w-full bg-blue-600 hover:bg-blue-700 text-white font-semibold py-2 rounded-lg shadow-sm transition duration-200"
+          >
+            Create Account
+          </button>
+        </form>
+      </div>
+    </div>
+  );
+};
+
+export default AcceptInvite;
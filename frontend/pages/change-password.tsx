@@ -1,6 +1,7 @@
 import { useState, useEffect, FormEvent } from "react";
 import axios from "axios";
 import { useRouter } from "next/router";
+import { apiErrorMessage } from "../utils/auth";
 import "../src/app/globals.css";
 
 const ChangePassword = () => {
@@ -73,10 +74,10 @@ const ChangePassword = () => {
       setError("");
       alert("✅ Password changed successfully!");
   
-      router.push(email === "admin@gmail.com" ? "/dashboard" : "/user-dashboard");
+      router.push(localStorage.getItem("role") !== "employee" ? "/dashboard" : "/user-dashboard");
     } catch (err) {
       if (axios.isAxiosError(err) && err.response) {
-        setError(err.response.data?.error || "An error occurred");
+        setError(apiErrorMessage(err.response.data, "An error occurred"));
       } else {
         setError("An unexpected error occurred");
       }
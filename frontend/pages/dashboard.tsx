@@ -2,6 +2,8 @@ import React, { useState, useEffect, FormEvent } from "react";
 import axios from "axios";
 import { useRouter } from "next/router";
 import ProtectedRoute from "../components/ProtectedRoute";
+import ImpersonationBanner from "../components/ImpersonationBanner";
+import { logout, startImpersonation } from "../utils/auth";
 import '../src/app/globals.css';
 import { useRef } from "react";
 import { AxiosError } from 'axios';
@@ -20,6 +22,7 @@ interface User {
   salary: number;
   join_date: string;
   years_of_experience: number;
+  etag: string;
 }
 
 export default function Dashboard() {
@@ -88,11 +91,15 @@ export default function Dashboard() {
     router.push("/change-password"); // Redirect to the password change page
   }
 
+  // The sort parameter for the selected column, e.g. "-salary" for descending
+  const sortParam = () => (sortField ? `${sortOrder === "desc" ? "-" : ""}${sortField}` : undefined);
+
   const fetchUsers = async (filters: Filters = {}, page = 1) => {
     try {
       const response = await axios.get("http://localhost:8080/users", {
         params: {
           ...filters,
+          sort: sortParam(),
           page,
           limit: usersPerPage,
         },
@@ -109,6 +116,27 @@ export default function Dashboard() {
       setError("Failed to load users. Please try again.");
     }
   };
+  // Downloads every user matching the current filters, not just this page
+  const handleExport = async (format: "csv" | "xlsx") => {
+    try {
+      const response = await axios.get("http://localhost:8080/users/export", {
+        params: { ...filters, sort: sortParam(), format },
+        headers: {
+          Authorization: `Bearer ${localStorage.getItem("token")}`,
+        },
+        responseType: "blob",
+      });
+      const url = URL.createObjectURL(response.data);
+      const link = document.createElement("a");
+      link.href = url;
+      link.download = `users.${format}`;
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      console.error("Error exporting users:", err);
+      setError("Failed to export users. Please try again.");
+    }
+  };
   const formatDate = (date: string) => {
     const [year, month, day] = new Date(date).toISOString().split("T")[0].split("-");
     return `${year}-${month}-${day}`;
@@ -116,7 +144,7 @@ export default function Dashboard() {
 
   useEffect(() => {
     fetchUsers(filters, currentPage);
-  }, [filters, currentPage]);
+  }, [filters, currentPage, sortField, sortOrder]);
   useEffect(() => {
     if (editingUser) {
       setFormData({
@@ -134,17 +162,50 @@ export default function Dashboard() {
       });
     }
   }, [editingUser]);
+  const [canImpersonate, setCanImpersonate] = useState(false);
+  const [isAdmin, setIsAdmin] = useState(false);
   useEffect(() => {
-    const email = localStorage.getItem("email");
-    if (email !== "admin@gmail.com") {
+    const role = localStorage.getItem("role");
+    if (!role || role === "employee") {
       router.push("/user-dashboard"); // Redirect non-admin users
     }
+    setCanImpersonate(role === "admin" && !localStorage.getItem("impersonated_by"));
+    setIsAdmin(role === "admin");
   }, []);
 
-  const handleLogout = () => {
-    // Clear any authentication tokens or data
-    localStorage.removeItem("token"); // Example: Remove token from localStorage
-    localStorage.removeItem("email"); // Clear email from localStorage
+  // See the dashboard as the given user sees it (admin only, recorded on the server)
+  const handleImpersonate = async (user: User) => {
+    const reason = window.prompt(`Why do you need to view the dashboard as ${user.email}?`);
+    if (!reason) return;
+    setError("");
+    try {
+      const response = await axios.post(
+        `http://localhost:8080/users/${user.id}/impersonate`,
+        { reason },
+        { headers: { Authorization: `Bearer ${localStorage.getItem("token")}` } }
+      );
+      startImpersonation(response.data);
+      if (response.data.role !== "employee") {
+        router.push("/dashboard");
+        window.location.reload();
+      } else {
+        router.push({
+          pathname: "/user-dashboard",
+          query: { user: JSON.stringify(response.data.user_data) },
+        });
+      }
+    } catch (err) {
+      if (err instanceof AxiosError && err.response?.data) {
+        setError(err.response.data.error || "Failed to impersonate user.");
+      } else {
+        setError("Failed to impersonate user.");
+      }
+    }
+  };
+
+  const handleLogout = async () => {
+    // Revoke the session and clear any authentication tokens or data
+    await logout();
     router.push("/login"); // Redirect to login page
   };
 
@@ -154,9 +215,19 @@ export default function Dashboard() {
     setSuccess(""); // Clear previous success
     try {
       if (editingUser) {
-        await axios.put(`http://localhost:8080/users/${editingUser.id}`, formData, {
+        // Only send the fields that were changed (JSON Merge Patch)
+        const changes = Object.fromEntries(
+          Object.entries(formData).filter(([field, value]) => {
+            const original =
+              field === "join_date" ? formatDate(editingUser.join_date) : editingUser[field as keyof User];
+            return String(value) !== String(original);
+          })
+        );
+        await axios.patch(`http://localhost:8080/users/${editingUser.id}`, changes, {
           headers: {
             Authorization: `Bearer ${localStorage.getItem("token")}`, // Send the token for authentication
+            "Content-Type": "application/merge-patch+json",
+            "If-Match": editingUser.etag, // Refused with 412 if someone else changed the user meanwhile
           },
         });
         setSuccess("User updated successfully!");
@@ -185,28 +256,56 @@ export default function Dashboard() {
       fetchUsers(filters, currentPage);
     } catch (err: unknown) {
       if (err instanceof AxiosError && err.response?.data) {
-        setError(err.response.data.error || "Validation failed.");
+        const { error, violations, current } = err.response.data;
+        setError(violations ? `${error}: ${violations.join(", ")}` : error || "Validation failed.");
+        if (err.response.status === 412 && current) {
+          setEditingUser(current); // Show the latest data with its new ETag
+          fetchUsers(filters, currentPage);
+        }
       } else {
         setError("An unexpected error occurred. Please try again.");
       }
     }
   };
 
-  const handleDelete = async (id: number) => {
+  // Edit the latest version of the user, not the possibly stale row of the list
+  const handleEdit = async (user: User) => {
+    setEditingUser(user);
+    try {
+      const response = await axios.get(`http://localhost:8080/users/${user.id}`, {
+        headers: { Authorization: `Bearer ${localStorage.getItem("token")}` },
+      });
+      setEditingUser(response.data);
+    } catch (err) {
+      if (err instanceof AxiosError && err.response?.status === 404) {
+        setEditingUser(null);
+        setError("This user no longer exists.");
+        fetchUsers(filters, currentPage);
+      }
+    }
+  };
+
+  const handleDelete = async (user: User) => {
     if (window.confirm("Are you sure you want to delete this user?")) {
       setError(""); // Clear previous error
       setSuccess(""); // Clear previous success
       try {
-        await axios.delete(`http://localhost:8080/users/${id}`, {
+        await axios.delete(`http://localhost:8080/users/${user.id}`, {
           headers: {
             Authorization: `Bearer ${localStorage.getItem("token")}`, // Send the token for authentication
+            "If-Match": user.etag, // Refused with 412 if someone else changed the user meanwhile
           },
         });
-        setSuccess("User deleted successfully!");
+        setSuccess("User moved to the trash. An admin can restore them from Deleted Users.");
         fetchUsers(filters, currentPage);
       } catch (err) {
         console.error("Error deleting users:", err); // Log the error
-        setError("Failed to delete user. Please try again.");
+        if (err instanceof AxiosError && err.response?.status === 412) {
+          setError(err.response.data.error);
+          fetchUsers(filters, currentPage);
+        } else {
+          setError("Failed to delete user. Please try again.");
+        }
       }
     }
   };
@@ -282,26 +381,19 @@ export default function Dashboard() {
 
   const totalPages = Math.ceil(totalUsers / usersPerPage);
 
+  // Sorting is done by the server so it holds across pages; the effect above refetches
   const sortUsers = (field: keyof User) => {
     // Toggle sorting order if the same field is clicked again
     const newSortOrder = sortField === field && sortOrder === "asc" ? "desc" : "asc";
     setSortField(field);
     setSortOrder(newSortOrder);
-
-    // Sort the users array
-    setUsers((prevUsers) => {
-      return [...prevUsers].sort((a, b) => {
-        if (a[field] < b[field]) return newSortOrder === "asc" ? -1 : 1;
-        if (a[field] > b[field]) return newSortOrder === "asc" ? 1 : -1;
-        return 0;
-      });
-    });
   };
 
 
   return (
     <ProtectedRoute>
       <div className="min-h-screen bg-gray-100 p-6">
+        <ImpersonationBanner />
         <div className="flex items-center justify-between relative mb-8">
           {/* Spacer to keep "Admin Dashboard" centered */}
           <div className="w-10"></div> {/* Empty spacer block for alignment */}
@@ -329,6 +421,32 @@ export default function Dashboard() {
                   >
                     Change Password
                   </button>
+                  <button
+                    onClick={() => router.push("/sessions")}
+                    className="block w-full px-4 py-2 text-left text-blue-600 hover:bg-blue-100"
+                  >
+                    Active Sessions
+                  </button>
+                  <button
+                    onClick={() => router.push("/login-history")}
+                    className="block w-full px-4 py-2 text-left text-blue-600 hover:bg-blue-100"
+                  >
+                    Login History
+                  </button>
+                  <button
+                    onClick={() => router.push("/import")}
+                    className="block w-full px-4 py-2 text-left text-blue-600 hover:bg-blue-100"
+                  >
+                    Import Users
+                  </button>
+                  {isAdmin && (
+                    <button
+                      onClick={() => router.push("/trash")}
+                      className="block w-full px-4 py-2 text-left text-blue-600 hover:bg-blue-100"
+                    >
+                      Deleted Users
+                    </button>
+                  )}
                   <button
                     onClick={handleLogout}
                     className="block w-full px-4 py-2 text-left text-blue-600 hover:bg-blue-100"
@@ -777,6 +895,20 @@ export default function Dashboard() {
               >
                 Clear Filters
               </button>
+              <button
+                type="button"
+                onClick={() => handleExport("csv")}
+                className="px-6 py-2 bg-blue-500 text-white rounded-lg hover:bg-blue-600"
+              >
+                Export CSV
+              </button>
+              <button
+                type="button"
+                onClick={() => handleExport("xlsx")}
+                className="px-6 py-2 bg-blue-500 text-white rounded-lg hover:bg-blue-600"
+              >
+                Export Excel
+              </button>
             </div>
           </div>
           <table className="w-full border-collapse">
@@ -855,7 +987,7 @@ export default function Dashboard() {
                     join_date: user.join_date,
                     years_of_experience: user.years_of_experience,
                   });
-                  setEditingUser(user);
+                  handleEdit(user);
 
                   if (formRef.current) {
                     formRef.current.scrollIntoView({ behavior: "smooth" });
@@ -866,11 +998,25 @@ export default function Dashboard() {
                 Edit
               </button>
               <button
-                onClick={() => handleDelete(user.id)}
+                onClick={() => handleDelete(user)}
                 className="px-3 py-1 bg-red-500 text-white rounded-lg hover:bg-red-600"
               >
                 Delete
               </button>
+              <button
+                onClick={() => router.push({ pathname: "/user-history", query: { id: user.id } })}
+                className="px-3 py-1 bg-indigo-500 text-white rounded-lg hover:bg-indigo-600"
+              >
+                History
+              </button>
+              {canImpersonate && (
+                <button
+                  onClick={() => handleImpersonate(user)}
+                  className="px-3 py-1 bg-gray-700 text-white rounded-lg hover:bg-gray-800"
+                >
+                  View as
+                </button>
+              )}
             </td>
           </tr>
         ))
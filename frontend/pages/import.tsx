@@ -0,0 +1,184 @@
+import { useState } from "react";
+import axios from "axios";
+import { useRouter } from "next/router";
+import "../src/app/globals.css";
+
+interface ImportRow {
+  row: number;
+  status: "created" | "valid" | "failed";
+  id?: number;
+  errors?: string[];
+}
+
+interface ImportReport {
+  dry_run: boolean;
+  committed: boolean;
+  total: number;
+  created: number;
+  failed: number;
+  rows: ImportRow[];
+}
+
+const fields = [
+  "first_name",
+  "last_name",
+  "gender",
+  "location",
+  "email",
+  "phone",
+  "department",
+  "role",
+  "salary",
+  "join_date",
+  "years_of_experience",
+];
+
+// Imports employees from a CSV or XLSX file: check it first, map columns if needed, then import
+const Import = () => {
+  const [file, setFile] = useState<File | null>(null);
+  const [mode, setMode] = useState("all_or_nothing");
+  const [columns, setColumns] = useState<string[]>([]);
+  const [mapping, setMapping] = useState<Record<string, string>>({});
+  const [report, setReport] = useState<ImportReport | null>(null);
+  const [error, setError] = useState("");
+  const router = useRouter();
+
+  const submit = async (dryRun: boolean) => {
+    if (!file) {
+      setError("Choose a CSV or XLSX file.");
+      return;
+    }
+    setError("");
+    setReport(null);
+
+    const form = new FormData();
+    form.append("file", file);
+    form.append("mode", mode);
+    form.append("dry_run", String(dryRun));
+    if (Object.keys(mapping).length > 0) {
+      form.append("mapping", JSON.stringify(mapping));
+    }
+
+    try {
+      const token = localStorage.getItem("token");
+      const response = await axios.post("http://localhost:8080/users/import", form, {
+        headers: { Authorization: `Bearer ${token}` },
+      });
+      setReport(response.data);
+    } catch (err) {
+      if (axios.isAxiosError(err) && err.response) {
+        if (err.response.data?.rows) {
+          setReport(err.response.data);
+        } else if (err.response.data?.columns) {
+          // The columns could not be matched to fields; let the user pick them
+          setColumns(err.response.data.columns);
+          setError((err.response.data.violations || []).join(", "));
+        } else {
+          setError(err.response.data?.error || "An error occurred");
+        }
+      } else {
+        setError("An unexpected error occurred");
+      }
+    }
+  };
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-sky-100 to-blue-200 flex items-center justify-center p-6">
+      <div className="w-full max-w-3xl bg-white rounded-2xl shadow-lg p-8">
+        <h1 className="text-3xl font-bold text-center text-blue-800 mb-2">Import Users</h1>
+        <p className="text-center text-gray-500 mb-6">
+          The first row of the file must hold the column headers.
+        </p>
+
+        {error && <div className="bg-red-100 border border-red-300 text-red-700 px-4 py-2 rounded mb-4">{error}</div>}
+
+        <div className="space-y-4">
+          <input
+            type="file"
+            accept=".csv,.xlsx"
+            onChange={(e) => {
+              setFile(e.target.files?.[0] || null);
+              setColumns([]);
+              setMapping({});
+              setReport(null);
+            }}
+            className="w-full text-gray-700"
+          />
+          <select
+            value={mode}
+            onChange={(e) => setMode(e.target.value)}
+            className="w-full border border-gray-300 rounded px-3 py-2 text-gray-700"
+          >
+            <option value="all_or_nothing">Import nothing if any row fails</option>
+            <option value="best_effort">Import the rows that are valid</option>
+          </select>
+
+          {columns.length > 0 && (
+            <div className="grid grid-cols-2 gap-2">
+              {fields.map((field) => (
+                <label key={field} className="flex items-center justify-between text-gray-700">
+                  <span className="mr-2">{field}</span>
+                  <select
+                    value={mapping[field] || ""}
+                    onChange={(e) => setMapping({ ...mapping, [field]: e.target.value })}
+                    className="border border-gray-300 rounded px-2 py-1"
+                  >
+                    <option value="">—</option>
+                    {columns.map((column) => (
+                      <option key={column} value={column}>
+                        {column}
+                      </option>
+                    ))}
+                  </select>
+                </label>
+              ))}
+            </div>
+          )}
+
+          <div className="flex gap-4">
+            <button
+              onClick={() => submit(true)}
+              className="flex-1 px-4 py-2 text-blue-600 border border-blue-300 rounded hover:bg-blue-100"
+            >
+              Check
+            </button>
+            <button
+              onClick={() => submit(false)}
+              className="flex-1 px-4 py-2 bg-blue-600 text-white rounded hover:bg-blue-700"
+            >
+              Import
+            </button>
+          </div>
+        </div>
+
+        {report && (
+          <div className="mt-6">
+            <p className="text-gray-700 mb-2">
+              {report.dry_run
+                ? `${report.total - report.failed} of ${report.total} rows can be imported.`
+                : `${report.created} of ${report.total} rows imported.`}
+            </p>
+            <ul className="space-y-2">
+              {report.rows
+                .filter((row) => row.status === "failed")
+                .map((row) => (
+                  <li key={row.row} className="border border-red-200 rounded p-2 text-sm text-red-700">
+                    Row {row.row}: {(row.errors || []).join(", ")}
+                  </li>
+                ))}
+            </ul>
+          </div>
+        )}
+
+        <button
+          onClick={() => router.back()}
+          className="w-full mt-6 text-blue-600 hover:underline focus:outline-none"
+        >
+          Back
+        </button>
+      </div>
+    </div>
+  );
+};
+
+export default Import;
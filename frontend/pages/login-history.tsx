@@ -0,0 +1,116 @@
+import { useState, useEffect } from "react";
+import axios from "axios";
+import { useRouter } from "next/router";
+import "../src/app/globals.css";
+
+interface LoginEvent {
+  id: number;
+  success: boolean;
+  method: string;
+  failure_reason: string | null;
+  ip_address: string | null;
+  user_agent: string | null;
+  flags: string[];
+  created_at: string;
+}
+
+const flagLabels: Record<string, string> = {
+  new_device: "New device",
+  new_ip_range: "New location",
+  failure_burst: "Many failed attempts",
+  after_failure_burst: "After many failed attempts",
+};
+
+// Shows the account's recent login attempts so the user can spot ones that were not them
+const LoginHistory = () => {
+  const [events, setEvents] = useState<LoginEvent[]>([]);
+  const [page, setPage] = useState(1);
+  const [total, setTotal] = useState(0);
+  const [error, setError] = useState("");
+  const router = useRouter();
+  const limit = 20;
+
+  useEffect(() => {
+    const fetchHistory = async () => {
+      try {
+        const token = localStorage.getItem("token");
+        const response = await axios.get("http://localhost:8080/me/login-history", {
+          headers: { Authorization: `Bearer ${token}` },
+          params: { page, limit },
+        });
+        setEvents(response.data.events);
+        setTotal(response.data.total);
+      } catch (err) {
+        console.error("Failed to fetch login history:", err);
+        setError("Failed to fetch login history. Please try again.");
+      }
+    };
+    fetchHistory();
+  }, [page]);
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-sky-100 to-blue-200 flex items-center justify-center p-6">
+      <div className="w-full max-w-3xl bg-white rounded-2xl shadow-lg p-8">
+        <h1 className="text-3xl font-bold text-center text-blue-800 mb-6">Login History</h1>
+
+        {error && <div className="bg-red-100 border border-red-300 text-red-700 px-4 py-2 rounded mb-4">{error}</div>}
+
+        <ul className="space-y-3">
+          {events.map((event) => (
+            <li key={event.id} className="border border-gray-200 rounded-lg p-4 text-gray-700">
+              <p className="font-medium">
+                {event.success ? (
+                  <span className="text-green-600">Signed in</span>
+                ) : (
+                  <span className="text-red-600">Failed ({event.failure_reason?.replace(/_/g, " ")})</span>
+                )}{" "}
+                with {event.method.replace(/_/g, " ")} · {new Date(event.created_at).toLocaleString()}
+              </p>
+              <p className="text-sm text-gray-500">
+                {event.ip_address} · {event.user_agent || "Unknown device"}
+              </p>
+              {event.flags.length > 0 && (
+                <p className="mt-1 space-x-2">
+                  {event.flags.map((flag) => (
+                    <span key={flag} className="px-2 py-0.5 text-xs bg-yellow-100 text-yellow-800 rounded">
+                      {flagLabels[flag] || flag}
+                    </span>
+                  ))}
+                </p>
+              )}
+            </li>
+          ))}
+        </ul>
+
+        <div className="flex justify-between items-center mt-6">
+          <button
+            onClick={() => setPage(page - 1)}
+            disabled={page <= 1}
+            className="px-3 py-1 text-blue-600 border border-blue-300 rounded disabled:opacity-50"
+          >
+            Previous
+          </button>
+          <span className="text-gray-600">
+            Page {page} of {Math.max(1, Math.ceil(total / limit))}
+          </span>
+          <button
+            onClick={() => setPage(page + 1)}
+            disabled={page * limit >= total}
+            className="px-3 py-1 text-blue-600 border border-blue-300 rounded disabled:opacity-50"
+          >
+            Next
+          </button>
+        </div>
+
+        <button
+          onClick={() => router.back()}
+          className="w-full mt-6 text-blue-600 hover:underline focus:outline-none"
+        >
+          Back
+        </button>
+      </div>
+    </div>
+  );
+};
+
+export default LoginHistory;
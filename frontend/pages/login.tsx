@@ -7,6 +7,11 @@ const Login = () => {
   const [email, setEmail] = useState("");
   const [password, setPassword] = useState("");
   const [error, setError] = useState("");
+  const [mfaToken, setMfaToken] = useState("");
+  const [code, setCode] = useState("");
+  const [ssoEnabled, setSsoEnabled] = useState(false);
+  const [magicLinkEnabled, setMagicLinkEnabled] = useState(false);
+  const [message, setMessage] = useState("");
   const router = useRouter();
 
   useEffect(() => {
@@ -14,16 +19,72 @@ const Login = () => {
     if (token) {
       router.push("/dashboard");
     }
+    axios
+      .get("http://localhost:8080/auth/oidc")
+      .then((response) => setSsoEnabled(response.data.enabled))
+      .catch(() => setSsoEnabled(false));
+    axios
+      .get("http://localhost:8080/login/magic-link")
+      .then((response) => setMagicLinkEnabled(response.data.enabled))
+      .catch(() => setMagicLinkEnabled(false));
   }, []);
 
+  // Email a single-use sign-in link instead of using the password
+  const handleMagicLink = async () => {
+    if (!email) {
+      setError("Enter your email first.");
+      return;
+    }
+    try {
+      const response = await axios.post("http://localhost:8080/login/magic-link", { email });
+      setError("");
+      setMessage(response.data.message);
+    } catch (err: unknown) {
+      setMessage("");
+      if (axios.isAxiosError(err) && err.response) {
+        setError(err.response.data?.error || "Could not send a sign-in link.");
+      } else {
+        setError("Could not send a sign-in link.");
+      }
+    }
+  };
+
+  // Send the browser to the company identity provider; it returns to /oidc-callback
+  const handleSso = async () => {
+    try {
+      // withCredentials so the browser keeps the cookie that ties the sign-on to it
+      const response = await axios.get("http://localhost:8080/auth/oidc/login", { withCredentials: true });
+      window.location.href = response.data.authorization_url;
+    } catch (err: unknown) {
+      if (axios.isAxiosError(err) && err.response) {
+        setError(err.response.data?.error || "Single sign-on is unavailable.");
+      } else {
+        setError("Single sign-on is unavailable.");
+      }
+    }
+  };
+
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     try {
-      const response = await axios.post("http://localhost:8080/login", { email, password });
-      const { token, user_data } = response.data;
+      // Second step for accounts with two-factor authentication
+      const response = mfaToken
+        ? await axios.post("http://localhost:8080/login/mfa", {
+            mfa_token: mfaToken,
+            ...(code.includes("-") ? { recovery_code: code } : { code }),
+          })
+        : await axios.post("http://localhost:8080/login", { email, password });
+      if (response.data.mfa_required) {
+        setMfaToken(response.data.mfa_token);
+        setError("");
+        return;
+      }
+      const { token, refresh_token, user_data, role } = response.data;
       localStorage.setItem("token", token);
+      localStorage.setItem("refresh_token", refresh_token);
       localStorage.setItem("email", email);
-      if (email === "admin@gmail.com") {
+      localStorage.setItem("role", role);
+      if (role !== "employee") {
         router.push("/dashboard");
       } else {
         router.push({
@@ -72,16 +133,61 @@ const Login = () => {
           />
         </div>
 
+        {mfaToken && (
+          <div className="mb-4">
+            <label className="block text-gray-600 mb-2">Authenticator code or recovery code</label>
+            <input
+              type="text"
+              className="w-full p-3 border border-gray-300 rounded-md text-gray-800 focus:outline-none focus:ring-2 focus:ring-blue-500"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              autoComplete="one-time-code"
+              required
+            />
+          </div>
+        )}
+
         {error && <p className="text-red-600 text-center mb-4">{error}</p>}
+        {message && <p className="text-green-600 text-center mb-4">{message}</p>}
 
         <button
           type="submit"
           className="w-full bg-blue-600 text-white p-3 rounded-md hover:bg-blue-700 transition-all focus:outline-none focus:ring-2 focus:ring-blue-500"
         >
-          Login
+          {mfaToken ? "Verify" : "Login"}
         </button>
 
+        {ssoEnabled && !mfaToken && (
+          <button
+            type="button"
+            onClick={handleSso}
+            className="w-full mt-3 bg-white border border-blue-600 text-blue-600 p-3 rounded-md hover:bg-blue-50 transition-all focus:outline-none focus:ring-2 focus:ring-blue-500"
+          >
+            Sign in with SSO
+          </button>
+        )}
+
+        {magicLinkEnabled && !mfaToken && (
+          <button
+            type="button"
+            onClick={handleMagicLink}
+            className="w-full mt-3 bg-white border border-gray-400 text-gray-700 p-3 rounded-md hover:bg-gray-50 transition-all focus:outline-none focus:ring-2 focus:ring-blue-500"
+          >
+            Email me a sign-in link
+          </button>
+        )}
+
         <div className="mt-6 text-center space-y-2">
+          <p className="text-gray-700">
+            Forgot your password?{" "}
+            <button
+              type="button"
+              onClick={() => router.push("/reset-password")}
+              className="text-blue-500 hover:underline focus:outline-none"
+            >
+              Reset it here
+            </button>
+          </p>
           <p className="text-gray-700">
             Already have an account?{" "}
             <button
@@ -0,0 +1,113 @@
+import { useEffect, useState } from "react";
+import axios from "axios";
+import { useRouter } from "next/router";
+import "../src/app/globals.css";
+
+// Emailed sign-in links open this page with ?token=
+const MagicLink = () => {
+  const [error, setError] = useState("");
+  const [mfaToken, setMfaToken] = useState("");
+  const [code, setCode] = useState("");
+  const router = useRouter();
+
+  const finishLogin = (data: { token: string; refresh_token: string; user_data: unknown; role: string; email: string }) => {
+    localStorage.setItem("token", data.token);
+    localStorage.setItem("refresh_token", data.refresh_token);
+    localStorage.setItem("email", data.email);
+    localStorage.setItem("role", data.role);
+    if (data.role !== "employee") {
+      router.replace("/dashboard");
+    } else {
+      router.replace({
+        pathname: "/user-dashboard",
+        query: { user: JSON.stringify(data.user_data) },
+      });
+    }
+  };
+
+  const showError = (err: unknown, fallback: string) => {
+    if (axios.isAxiosError(err) && err.response) {
+      setError(err.response.data?.error || fallback);
+    } else {
+      setError(fallback);
+    }
+  };
+
+  useEffect(() => {
+    if (!router.isReady) return;
+
+    const { token } = router.query;
+    if (typeof token !== "string") {
+      setError("This sign-in link is incomplete.");
+      return;
+    }
+
+    // Links are only redeemed by this request, so mail scanners opening the page cannot use them up
+    axios
+      .post("http://localhost:8080/login/magic-link/verify", { token })
+      .then((response) => {
+        if (response.data.mfa_required) {
+          setMfaToken(response.data.mfa_token);
+          return;
+        }
+        finishLogin(response.data);
+      })
+      .catch((err: unknown) => showError(err, "Invalid or expired sign-in link."));
+  }, [router.isReady]);
+
+  // Second step for accounts with two-factor authentication
+  const handleVerify = async (e: React.FormEvent) => {
+    e.preventDefault();
+    try {
+      const response = await axios.post("http://localhost:8080/login/mfa", {
+        mfa_token: mfaToken,
+        ...(code.includes("-") ? { recovery_code: code } : { code }),
+      });
+      finishLogin(response.data);
+    } catch (err: unknown) {
+      showError(err, "Invalid verification code.");
+    }
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-100">
+      <div className="bg-white p-10 rounded-xl shadow-md w-full max-w-md text-center">
+        {mfaToken ? (
+          <form onSubmit={handleVerify}>
+            <label className="block text-gray-600 mb-2">Authenticator code or recovery code</label>
+            <input
+              type="text"
+              className="w-full p-3 border border-gray-300 rounded-md text-gray-800 focus:outline-none focus:ring-2 focus:ring-blue-500 mb-4"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              autoComplete="one-time-code"
+              required
+            />
+            {error && <p className="text-red-600 mb-4">{error}</p>}
+            <button
+              type="submit"
+              className="w-full bg-blue-600 text-white p-3 rounded-md hover:bg-blue-700 transition-all focus:outline-none focus:ring-2 focus:ring-blue-500"
+            >
+              Verify
+            </button>
+          </form>
+        ) : error ? (
+          <>
+            <p className="text-red-600 mb-4">{error}</p>
+            <button
+              type="button"
+              onClick={() => router.push("/login")}
+              className="text-blue-500 hover:underline focus:outline-none"
+            >
+              Back to login
+            </button>
+          </>
+        ) : (
+          <p className="text-gray-700">Signing you in...</p>
+        )}
+      </div>
+    </div>
+  );
+};
+
+export default MagicLink;
@@ -0,0 +1,68 @@
+import { useEffect, useState } from "react";
+import axios from "axios";
+import { useRouter } from "next/router";
+import "../src/app/globals.css";
+
+// The identity provider redirects here with ?code=&state= after single sign-on
+const OidcCallback = () => {
+  const [error, setError] = useState("");
+  const router = useRouter();
+
+  useEffect(() => {
+    if (!router.isReady) return;
+
+    const { code, state, error_description } = router.query;
+    if (typeof code !== "string" || typeof state !== "string") {
+      setError(typeof error_description === "string" ? error_description : "Single sign-on was cancelled.");
+      return;
+    }
+
+    axios
+      .post("http://localhost:8080/auth/oidc/callback", { code, state }, { withCredentials: true })
+      .then((response) => {
+        const { token, refresh_token, user_data, role, email } = response.data;
+        localStorage.setItem("token", token);
+        localStorage.setItem("refresh_token", refresh_token);
+        localStorage.setItem("email", email);
+        localStorage.setItem("role", role);
+        if (role !== "employee") {
+          router.replace("/dashboard");
+        } else {
+          router.replace({
+            pathname: "/user-dashboard",
+            query: { user: JSON.stringify(user_data) },
+          });
+        }
+      })
+      .catch((err: unknown) => {
+        if (axios.isAxiosError(err) && err.response) {
+          setError(err.response.data?.error || "Single sign-on failed.");
+        } else {
+          setError("Single sign-on failed.");
+        }
+      });
+  }, [router.isReady]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-100">
+      <div className="bg-white p-10 rounded-xl shadow-md w-full max-w-md text-center">
+        {error ? (
+          <>
+            <p className="text-red-600 mb-4">{error}</p>
+            <button
+              type="button"
+              onClick={() => router.push("/login")}
+              className="text-blue-500 hover:underline focus:outline-none"
+            >
+              Back to login
+            </button>
+          </>
+        ) : (
+          <p className="text-gray-700">Signing you in...</p>
+        )}
+      </div>
+    </div>
+  );
+};
+
+export default OidcCallback;
@@ -1,6 +1,7 @@
 import { useState } from "react";
 import axios from "axios";
 import { useRouter } from "next/router";
+import { apiErrorMessage } from "../utils/auth";
 import '../src/app/globals.css';
 
 const Register = () => {
@@ -24,11 +25,6 @@ const Register = () => {
     setSuccess("");
 
     // Validation checks
-    if (email === "admin@gmail.com") {
-      setError("Registration with admin@gmail.com is not allowed.");
-      return;
-    }
-
     if (!validatePassword(password)) {
       setError(
         "Password must be at least 8 characters long and include at least one number and one special character."
@@ -42,24 +38,7 @@ const Register = () => {
     }
 
     try {
-      // Check if email already exists
-      const response = await axios.post("http://localhost:8080/check-email", { email });
-      if (response.data.exists) {
-        setError("Email is already registered. Redirecting to login...");
-        setTimeout(() => {
-          router.push("/login");
-        }, 2000); // Redirect to login after 2 seconds
-        return;
-      }
-
-      // Check if user is authorized to register
-      const response2 = await axios.post("http://localhost:8080/check-email-exists", { email });
-      if (!response2.data.exists) {
-        setError("You are not authorized to register as you are not a member.");
-        return;
-      }
-
-      // API call to register user if email does not exist and user is authorized to register
+      // The server checks that the email belongs to an employee without an account
       await axios.post("http://localhost:8080/register", { email, password });
       setSuccess("Registration successful! Redirecting to login...");
       setTimeout(() => {
@@ -67,7 +46,11 @@ const Register = () => {
       }, 2000); // Redirect after 2 seconds
     } catch (err) {
       console.error("Error registering users:", err); // Log the error
-      setError("Error registering user. Please try again.");
+      if (axios.isAxiosError(err) && err.response?.data?.error) {
+        setError(apiErrorMessage(err.response.data, "Error registering user. Please try again."));
+      } else {
+        setError("Error registering user. Please try again.");
+      }
     }
   };
 
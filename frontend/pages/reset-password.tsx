@@ -0,0 +1,107 @@
+import { useState, FormEvent } from "react";
+import axios from "axios";
+import { useRouter } from "next/router";
+import { apiErrorMessage } from "../utils/auth";
+import "../src/app/globals.css";
+
+// Without a ?token= query this page requests a reset link, with one it sets the new password
+const ResetPassword = () => {
+  const [email, setEmail] = useState("");
+  const [newPassword, setNewPassword] = useState("");
+  const [confirmPassword, setConfirmPassword] = useState("");
+  const [error, setError] = useState("");
+  const [success, setSuccess] = useState("");
+  const router = useRouter();
+  const token = typeof router.query.token === "string" ? router.query.token : "";
+
+  const handleForgot = async (e: FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    setError("");
+    setSuccess("");
+
+    try {
+      const response = await axios.post("http://localhost:8080/password/forgot", { email });
+      setSuccess(response.data.message);
+    } catch (err) {
+      if (axios.isAxiosError(err) && err.response) {
+        setError(apiErrorMessage(err.response.data, "An error occurred"));
+      } else {
+        setError("An unexpected error occurred");
+      }
+    }
+  };
+
+  const handleReset = async (e: FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    setError("");
+    setSuccess("");
+
+    if (newPassword !== confirmPassword) {
+      setError("New passwords do not match.");
+      return;
+    }
+
+    try {
+      await axios.post("http://localhost:8080/password/reset", { token, newPassword });
+      alert("✅ Password reset successfully! Please log in.");
+      router.push("/login");
+    } catch (err) {
+      if (axios.isAxiosError(err) && err.response) {
+        setError(apiErrorMessage(err.response.data, "An error occurred"));
+      } else {
+        setError("An unexpected error occurred");
+      }
+    }
+  };
+
+  const inputClass =
+    "w-full px-4 py-2 border border-gray-300 rounded-lg text-black bg-gray-100 focus:outline-none focus:ring-2 focus:ring-blue-500";
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-sky-100 to-blue-200 flex items-center justify-center p-6">
+      <div className="w-full max-w-md bg-white rounded-2xl shadow-lg p-8">
+        <h1 className="text-3xl font-bold text-center text-blue-800 mb-6">🔑 Reset Password</h1>
+
+        {error && <div className="bg-red-100 border border-red-300 text-red-700 px-4 py-2 rounded mb-4">{error}</div>}
+        {success && <div className="bg-green-100 border border-green-300 text-green-700 px-4 py-2 rounded mb-4">{success}</div>}
+
+        {token ? (
+          <form onSubmit={handleReset} className="space-y-5">
+            <div>
+              <label className="block text-gray-700 font-medium mb-1">New Password</label>
+              <input type="password" value={newPassword} onChange={(e) => setNewPassword(e.target.value)} className={inputClass} required />
+            </div>
+
+            <div>
+              <label className="block text-gray-700 font-medium mb-1">Confirm New Password</label>
+              <input type="password" value={confirmPassword} onChange={(e) => setConfirmPassword(e.target.value)} className={inputClass} required />
+            </div>
+
+            <button
+              type="submit"
+              className="w-full bg-blue-600 hover:bg-blue-700 text-white font-semibold py-2 rounded-lg shadow-sm transition duration-200"
+            >
+              Reset Password
+            </button>
+          </form>
+        ) : (
+          <form onSubmit={handleForgot} className="space-y-5">
+            <div>
+              <label className="block text-gray-700 font-medium mb-1">Email</label>
+              <input type="email" value={email} onChange={(e) => setEmail(e.target.value)} className={inputClass} required />
+            </div>
+
+            <button
+              type="submit"
+              className="w-full bg-blue-600 hover:bg-blue-700 text-white font-semibold py-2 rounded-lg shadow-sm transition duration-200"
+            >
+              Send Reset Link
+            </button>
+          </form>
+        )}
+      </div>
+    </div>
+  );
+};
+
+export default ResetPassword;
@@ -0,0 +1,101 @@
+import { useState, useEffect } from "react";
+import axios from "axios";
+import { useRouter } from "next/router";
+import { logout } from "../utils/auth";
+import "../src/app/globals.css";
+
+interface Session {
+  id: string;
+  ip_address: string;
+  user_agent: string;
+  created_at: string;
+  last_seen_at: string;
+  current: boolean;
+}
+
+// Lists where the account is signed in and lets the user sign other sessions out
+const Sessions = () => {
+  const [sessions, setSessions] = useState<Session[]>([]);
+  const [error, setError] = useState("");
+  const router = useRouter();
+
+  const fetchSessions = async () => {
+    try {
+      const token = localStorage.getItem("token");
+      const response = await axios.get("http://localhost:8080/me/sessions", {
+        headers: { Authorization: `Bearer ${token}` },
+      });
+      setSessions(response.data.sessions);
+    } catch (err) {
+      console.error("Failed to fetch sessions:", err);
+      setError("Failed to fetch sessions. Please try again.");
+    }
+  };
+
+  useEffect(() => {
+    fetchSessions();
+  }, []);
+
+  const handleSignOut = async (session: Session) => {
+    try {
+      const token = localStorage.getItem("token");
+      await axios.delete(`http://localhost:8080/me/sessions/${session.id}`, {
+        headers: { Authorization: `Bearer ${token}` },
+      });
+      if (session.current) {
+        await logout();
+        router.push("/login");
+        return;
+      }
+      fetchSessions();
+    } catch (err) {
+      if (axios.isAxiosError(err) && err.response) {
+        setError(err.response.data?.error || "An error occurred");
+      } else {
+        setError("An unexpected error occurred");
+      }
+    }
+  };
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-sky-100 to-blue-200 flex items-center justify-center p-6">
+      <div className="w-full max-w-2xl bg-white rounded-2xl shadow-lg p-8">
+        <h1 className="text-3xl font-bold text-center text-blue-800 mb-6">Active Sessions</h1>
+
+        {error && <div className="bg-red-100 border border-red-300 text-red-700 px-4 py-2 rounded mb-4">{error}</div>}
+
+        <ul className="space-y-3">
+          {sessions.map((session) => (
+            <li key={session.id} className="flex justify-between items-center border border-gray-200 rounded-lg p-4">
+              <div className="text-gray-700">
+                <p className="font-medium">
+                  {session.user_agent || "Unknown device"}
+                  {session.current && <span className="ml-2 text-green-600 text-sm">(this device)</span>}
+                </p>
+                <p className="text-sm text-gray-500">
+                  {session.ip_address} · signed in {new Date(session.created_at).toLocaleString()} · last seen{" "}
+                  {new Date(session.last_seen_at).toLocaleString()}
+                </p>
+              </div>
+              <button
+                onClick={() => handleSignOut(session)}
+                className="ml-4 px-3 py-1 text-red-600 border border-red-300 rounded hover:bg-red-100"
+              >
+                Sign out
+              </button>
+            </li>
+          ))}
+        </ul>
+
+        <button
+          onClick={() => router.back()}
+          className="w-full mt-6 text-blue-600 hover:underline focus:outline-none"
+        >
+          Back
+        </button>
+      </div>
+    </div>
+  );
+};
+
+export default Sessions;
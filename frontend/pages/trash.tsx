@@ -0,0 +1,135 @@
+import { useState, useEffect } from "react";
+import axios from "axios";
+import { useRouter } from "next/router";
+import "../src/app/globals.css";
+
+interface DeletedUser {
+  id: number;
+  first_name: string;
+  last_name: string;
+  email: string;
+  department: string;
+  deleted_at: string;
+  deleted_by: string | null;
+}
+
+// Lists deleted users until they are purged and lets an admin restore them
+const Trash = () => {
+  const [users, setUsers] = useState<DeletedUser[]>([]);
+  const [page, setPage] = useState(1);
+  const [total, setTotal] = useState(0);
+  const [retentionDays, setRetentionDays] = useState(0);
+  const [error, setError] = useState("");
+  const [success, setSuccess] = useState("");
+  const router = useRouter();
+  const limit = 20;
+
+  const fetchTrash = async () => {
+    try {
+      const token = localStorage.getItem("token");
+      const response = await axios.get("http://localhost:8080/users/trash", {
+        headers: { Authorization: `Bearer ${token}` },
+        params: { page, limit },
+      });
+      setUsers(response.data.users || []);
+      setTotal(response.data.total);
+      setRetentionDays(response.data.retention_days);
+    } catch (err) {
+      console.error("Failed to fetch deleted users:", err);
+      setError("Failed to fetch deleted users. Please try again.");
+    }
+  };
+
+  useEffect(() => {
+    fetchTrash();
+  }, [page]);
+
+  const handleRestore = async (user: DeletedUser) => {
+    setError("");
+    setSuccess("");
+    try {
+      const token = localStorage.getItem("token");
+      await axios.post(`http://localhost:8080/users/${user.id}/restore`, null, {
+        headers: { Authorization: `Bearer ${token}` },
+      });
+      setSuccess(`${user.first_name} ${user.last_name} was restored.`);
+      fetchTrash();
+    } catch (err) {
+      if (axios.isAxiosError(err) && err.response) {
+        setError(err.response.data?.error || "An error occurred");
+      } else {
+        setError("An unexpected error occurred");
+      }
+    }
+  };
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-sky-100 to-blue-200 flex items-center justify-center p-6">
+      <div className="w-full max-w-3xl bg-white rounded-2xl shadow-lg p-8">
+        <h1 className="text-3xl font-bold text-center text-blue-800 mb-2">Deleted Users</h1>
+        {retentionDays > 0 && (
+          <p className="text-center text-gray-500 mb-6">
+            Deleted users are removed for good after {retentionDays} days.
+          </p>
+        )}
+
+        {error && <div className="bg-red-100 border border-red-300 text-red-700 px-4 py-2 rounded mb-4">{error}</div>}
+        {success && (
+          <div className="bg-green-100 border border-green-300 text-green-700 px-4 py-2 rounded mb-4">{success}</div>
+        )}
+
+        {users.length === 0 && <p className="text-center text-gray-500">The trash is empty.</p>}
+        <ul className="space-y-3">
+          {users.map((user) => (
+            <li key={user.id} className="flex justify-between items-center border border-gray-200 rounded-lg p-4">
+              <div className="text-gray-700">
+                <p className="font-medium">
+                  {user.first_name} {user.last_name} · {user.email}
+                </p>
+                <p className="text-sm text-gray-500">
+                  {user.department} · deleted {new Date(user.deleted_at).toLocaleString()}
+                  {user.deleted_by && ` by ${user.deleted_by}`}
+                </p>
+              </div>
+              <button
+                onClick={() => handleRestore(user)}
+                className="ml-4 px-3 py-1 text-green-600 border border-green-300 rounded hover:bg-green-100"
+              >
+                Restore
+              </button>
+            </li>
+          ))}
+        </ul>
+
+        <div className="flex justify-between items-center mt-6">
+          <button
+            onClick={() => setPage(page - 1)}
+            disabled={page <= 1}
+            className="px-3 py-1 text-blue-600 border border-blue-300 rounded disabled:opacity-50"
+          >
+            Previous
+          </button>
+          <span className="text-gray-600">
+            Page {page} of {Math.max(1, Math.ceil(total / limit))}
+          </span>
+          <button
+            onClick={() => setPage(page + 1)}
+            disabled={page * limit >= total}
+            className="px-3 py-1 text-blue-600 border border-blue-300 rounded disabled:opacity-50"
+          >
+            Next
+          </button>
+        </div>
+
+        <button
+          onClick={() => router.back()}
+          className="w-full mt-6 text-blue-600 hover:underline focus:outline-none"
+        >
+          Back
+        </button>
+      </div>
+    </div>
+  );
+};
+
+export default Trash;
@@ -1,6 +1,8 @@
 import { useRouter } from "next/router";
 import React, { useState, useEffect } from "react";
 import ProtectedRoute from "../components/ProtectedRoute";
+import ImpersonationBanner from "../components/ImpersonationBanner";
+import { logout } from "../utils/auth";
 import "../src/app/globals.css";
 
 interface UserData {
@@ -56,10 +58,8 @@ const UserDashboard = () => {
     router.push("/change-password");
   };
 
-  const handleLogout = () => {
-    localStorage.removeItem("token");
-    localStorage.removeItem("email");
-    localStorage.removeItem("user");
+  const handleLogout = async () => {
+    await logout();
     router.push("/login");
   };
 
@@ -82,6 +82,7 @@ const UserDashboard = () => {
   return (
     <ProtectedRoute>
       <div className="min-h-screen p-8 bg-gradient-to-r from-blue-50 to-indigo-100">
+        <ImpersonationBanner />
         <div className="flex justify-between items-center mb-6">
           <h1 className="text-3xl font-bold text-indigo-700">User Dashboard</h1>
           <div className="relative">
@@ -99,6 +100,18 @@ const UserDashboard = () => {
                 >
                   Change Password
                 </button>
+                <button
+                  onClick={() => router.push("/sessions")}
+                  className="block w-full px-4 py-2 text-left text-indigo-600 hover:bg-indigo-100"
+                >
+                  Active Sessions
+                </button>
+                <button
+                  onClick={() => router.push("/login-history")}
+                  className="block w-full px-4 py-2 text-left text-indigo-600 hover:bg-indigo-100"
+                >
+                  Login History
+                </button>
                 <button
                   onClick={handleLogout}
                   className="block w-full px-4 py-2 text-left text-red-600 hover:bg-red-100"
@@ -0,0 +1,135 @@
+import { useState, useEffect } from "react";
+import axios from "axios";
+import { useRouter } from "next/router";
+import "../src/app/globals.css";
+
+interface FieldChange {
+  before: unknown;
+  after: unknown;
+}
+
+interface AuditEvent {
+  id: number;
+  actor: string;
+  impersonator: string | null;
+  action: string;
+  changes: Record<string, FieldChange>;
+  request_id: string | null;
+  ip_address: string | null;
+  created_at: string;
+  redacted_at?: string;
+}
+
+const actionLabels: Record<string, string> = {
+  "user.created": "Created",
+  "user.updated": "Updated",
+  "user.deleted": "Deleted",
+  "user.restored": "Restored",
+  "user.purged": "Purged",
+};
+
+const formatValue = (value: unknown) => (value === null || value === undefined ? "—" : String(value));
+
+// Shows who changed an employee record, when, and what changed
+const UserHistory = () => {
+  const [events, setEvents] = useState<AuditEvent[]>([]);
+  const [page, setPage] = useState(1);
+  const [total, setTotal] = useState(0);
+  const [error, setError] = useState("");
+  const router = useRouter();
+  const { id } = router.query;
+  const limit = 20;
+
+  useEffect(() => {
+    if (!id) return;
+    const fetchHistory = async () => {
+      try {
+        const token = localStorage.getItem("token");
+        const response = await axios.get(`http://localhost:8080/users/${id}/history`, {
+          headers: { Authorization: `Bearer ${token}` },
+          params: { page, limit },
+        });
+        setEvents(response.data.events);
+        setTotal(response.data.total);
+      } catch (err) {
+        if (axios.isAxiosError(err) && err.response) {
+          setError(err.response.data?.error || "Failed to fetch history.");
+        } else {
+          setError("Failed to fetch history. Please try again.");
+        }
+      }
+    };
+    fetchHistory();
+  }, [id, page]);
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-sky-100 to-blue-200 flex items-center justify-center p-6">
+      <div className="w-full max-w-3xl bg-white rounded-2xl shadow-lg p-8">
+        <h1 className="text-3xl font-bold text-center text-blue-800 mb-6">Change History</h1>
+
+        {error && <div className="bg-red-100 border border-red-300 text-red-700 px-4 py-2 rounded mb-4">{error}</div>}
+
+        {!error && events.length === 0 && <p className="text-center text-gray-500">No recorded changes.</p>}
+        <ul className="space-y-3">
+          {events.map((event) => (
+            <li key={event.id} className="border border-gray-200 rounded-lg p-4 text-gray-700">
+              <p className="font-medium">
+                {actionLabels[event.action] || event.action} by {event.actor}
+                {event.impersonator && ` (impersonated by ${event.impersonator})`} ·{" "}
+                {new Date(event.created_at).toLocaleString()}
+              </p>
+              {Object.keys(event.changes).length > 0 && (
+                <ul className="mt-2 text-sm">
+                  {Object.entries(event.changes).map(([field, change]) => (
+                    <li key={field}>
+                      <span className="font-medium">{field.replace(/_/g, " ")}</span>
+                      {event.redacted_at ? (
+                        " changed (values removed when the record was purged)"
+                      ) : (
+                        <>
+                          : {formatValue(change.before)} → {formatValue(change.after)}
+                        </>
+                      )}
+                    </li>
+                  ))}
+                </ul>
+              )}
+              <p className="text-xs text-gray-400 mt-1">
+                {event.ip_address} · request {event.request_id || "unknown"}
+              </p>
+            </li>
+          ))}
+        </ul>
+
+        <div className="flex justify-between items-center mt-6">
+          <button
+            onClick={() => setPage(page - 1)}
+            disabled={page <= 1}
+            className="px-3 py-1 text-blue-600 border border-blue-300 rounded disabled:opacity-50"
+          >
+            Previous
+          </button>
+          <span className="text-gray-600">
+            Page {page} of {Math.max(1, Math.ceil(total / limit))}
+          </span>
+          <button
+            onClick={() => setPage(page + 1)}
+            disabled={page * limit >= total}
+            className="px-3 py-1 text-blue-600 border border-blue-300 rounded disabled:opacity-50"
+          >
+            Next
+          </button>
+        </div>
+
+        <button
+          onClick={() => router.back()}
+          className="w-full mt-6 text-blue-600 hover:underline focus:outline-none"
+        >
+          Back
+        </button>
+      </div>
+    </div>
+  );
+};
+
+export default UserHistory;
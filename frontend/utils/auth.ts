@@ -1,4 +1,6 @@
 // utils/auth.ts
+import axios from "axios";
+
 export const isTokenValid = (token: string | null): boolean => {
   if (!token) return false;
 
@@ -21,3 +23,71 @@ export const isTokenValid = (token: string | null): boolean => {
     return false;
   }
 };
+
+// Revoke the current session on the server and clear local auth data
+export const logout = async (): Promise<void> => {
+  const token = localStorage.getItem("token");
+  if (token) {
+    try {
+      await axios.post("http://localhost:8080/logout", {}, {
+        headers: { Authorization: `Bearer ${token}` },
+      });
+    } catch (err) {
+      console.error("Failed to revoke session:", err);
+    }
+  }
+  localStorage.removeItem("token");
+  localStorage.removeItem("refresh_token");
+  localStorage.removeItem("email");
+  localStorage.removeItem("role");
+  localStorage.removeItem("user");
+  localStorage.removeItem("impersonated_by");
+  localStorage.removeItem("impersonator_session");
+};
+
+// Keys of the signed-in session that are swapped out while impersonating
+const sessionKeys = ["token", "refresh_token", "email", "role"];
+
+// Switch to an impersonation token (response of POST /users/:id/impersonate), keeping the admin's session aside
+export const startImpersonation = (data: { token: string; email: string; role: string; impersonated_by: string }): void => {
+  const saved: Record<string, string | null> = {};
+  sessionKeys.forEach((key) => (saved[key] = localStorage.getItem(key)));
+  localStorage.setItem("impersonator_session", JSON.stringify(saved));
+  localStorage.setItem("impersonated_by", data.impersonated_by);
+  localStorage.setItem("token", data.token);
+  localStorage.setItem("email", data.email);
+  localStorage.setItem("role", data.role);
+  localStorage.removeItem("refresh_token");
+  localStorage.removeItem("user");
+};
+
+// End the impersonation on the server and restore the admin's session
+export const endImpersonation = async (): Promise<void> => {
+  const token = localStorage.getItem("token");
+  try {
+    await axios.post("http://localhost:8080/impersonation/end", {}, {
+      headers: { Authorization: `Bearer ${token}` },
+    });
+  } catch (err) {
+    console.error("Failed to end impersonation:", err);
+  }
+  const saved = JSON.parse(localStorage.getItem("impersonator_session") || "{}");
+  sessionKeys.forEach((key) => {
+    if (saved[key]) {
+      localStorage.setItem(key, saved[key]);
+    } else {
+      localStorage.removeItem(key);
+    }
+  });
+  localStorage.removeItem("impersonated_by");
+  localStorage.removeItem("impersonator_session");
+  localStorage.removeItem("user");
+};
+
+// Build a message from an API error response, listing password policy violations if present
+export const apiErrorMessage = (data: { error?: string; violations?: { message: string }[] } | undefined, fallback: string): string => {
+  if (data?.violations?.length) {
+    return data.violations.map((v) => v.message).join(". ");
+  }
+  return data?.error || fallback;
+};
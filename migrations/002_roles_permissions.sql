@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(30) UNIQUE NOT NULL,
+    description VARCHAR(100) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL,
+    description VARCHAR(100) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access to every employee record and account'),
+    ('hr_manager', 'Manages employee records'),
+    ('department_manager', 'Views employee records'),
+    ('employee', 'Views their own profile')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'List and view employee records'),
+    ('users:write', 'Create and update employee records'),
+    ('users:delete', 'Delete employee records'),
+    ('roles:manage', 'Assign roles to accounts')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE (r.name = 'admin')
+   OR (r.name = 'hr_manager' AND p.name IN ('users:read', 'users:write'))
+   OR (r.name = 'department_manager' AND p.name = 'users:read')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE credentials ADD COLUMN IF NOT EXISTS role_id INTEGER REFERENCES roles(id);
+
+UPDATE credentials SET role_id = (SELECT id FROM roles WHERE name = 'employee') WHERE role_id IS NULL;
+
+ALTER TABLE credentials ALTER COLUMN role_id SET NOT NULL;
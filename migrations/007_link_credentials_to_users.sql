@@ -0,0 +1,6 @@
+ALTER TABLE credentials ADD COLUMN IF NOT EXISTS user_id INTEGER UNIQUE REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE credentials ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE credentials ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP;
+
+-- Link existing logins to their employee record; the bootstrap admin may have none
+UPDATE credentials c SET user_id = u.id FROM users u WHERE u.email = c.email AND c.user_id IS NULL;
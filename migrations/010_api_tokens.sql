@@ -0,0 +1,39 @@
+INSERT INTO permissions (name, description) VALUES
+    ('users:salary:read', 'View employee salaries')
+ON CONFLICT (name) DO NOTHING;
+
+-- Every role that could already list employees keeps seeing salaries
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name IN ('admin', 'hr_manager', 'department_manager') AND p.name = 'users:salary:read'
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(30) UNIQUE NOT NULL,
+    description VARCHAR(100) NOT NULL DEFAULT '',
+    created_by VARCHAR(30) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    disabled_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    token_prefix VARCHAR(16) NOT NULL,
+    credential_id INTEGER REFERENCES credentials(id) ON DELETE CASCADE,
+    service_account_id INTEGER REFERENCES service_accounts(id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL,
+    created_by VARCHAR(30) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMP,
+    -- A token belongs to exactly one person or service account
+    CHECK ((credential_id IS NULL) <> (service_account_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_credential_id ON api_tokens(credential_id);
+CREATE INDEX IF NOT EXISTS idx_api_tokens_service_account_id ON api_tokens(service_account_id);
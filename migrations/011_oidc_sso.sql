@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    state_hash VARCHAR(64) PRIMARY KEY,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Identity provider subject ("sub") an account is linked to after its first single sign-on
+ALTER TABLE credentials ADD COLUMN IF NOT EXISTS oidc_subject VARCHAR(255) UNIQUE;
@@ -0,0 +1,11 @@
+-- Keys used to sign JWTs. A key is published in /.well-known/jwks.json from
+-- creation, signs new tokens from activates_at, and is removed at expires_at
+-- (set once a newer key takes over).
+CREATE TABLE IF NOT EXISTS signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    activates_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP
+);
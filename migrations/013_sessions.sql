@@ -0,0 +1,6 @@
+-- Each refresh token family is one signed-in session
+ALTER TABLE token_families ADD COLUMN IF NOT EXISTS ip_address VARCHAR(45);
+ALTER TABLE token_families ADD COLUMN IF NOT EXISTS user_agent VARCHAR(255);
+ALTER TABLE token_families ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_token_families_email ON token_families(email);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS impersonation_sessions (
+    id SERIAL PRIMARY KEY,
+    actor_email VARCHAR(30) NOT NULL,
+    subject_email VARCHAR(30) NOT NULL,
+    subject_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reason VARCHAR(255) NOT NULL,
+    jti VARCHAR(64) UNIQUE NOT NULL,
+    ip_address VARCHAR(45),
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonation_sessions_subject_user_id ON impersonation_sessions(subject_user_id);
+
+-- Every request made with an impersonation token
+CREATE TABLE IF NOT EXISTS impersonation_actions (
+    id SERIAL PRIMARY KEY,
+    impersonation_id INTEGER NOT NULL REFERENCES impersonation_sessions(id) ON DELETE CASCADE,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    status INTEGER NOT NULL,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonation_actions_impersonation_id ON impersonation_actions(impersonation_id);
@@ -0,0 +1,12 @@
+-- Single-use email sign-in links; the link itself is a signed token naming the jti
+CREATE TABLE IF NOT EXISTS magic_links (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(30) NOT NULL,
+    jti VARCHAR(64) UNIQUE NOT NULL,
+    ip_address VARCHAR(45),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_magic_links_email ON magic_links(email);
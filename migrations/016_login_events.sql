@@ -0,0 +1,17 @@
+-- Every login attempt, successful or not. email is what was typed and may not
+-- belong to an account.
+CREATE TABLE IF NOT EXISTS login_events (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    success BOOLEAN NOT NULL,
+    method VARCHAR(20) NOT NULL,
+    failure_reason VARCHAR(50),
+    ip_address VARCHAR(45),
+    ip_range VARCHAR(50),
+    user_agent VARCHAR(255),
+    flags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_events_email_created_at ON login_events(LOWER(email), created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_login_events_created_at ON login_events(created_at DESC);
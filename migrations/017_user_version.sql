@@ -0,0 +1,2 @@
+-- Incremented on every change so concurrent edits can be detected (ETag / If-Match)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
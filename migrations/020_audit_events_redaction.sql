@@ -0,0 +1,35 @@
+-- Purging a record from the trash must not leave its personal data in the
+-- audit log. The log stays append-only except for one exception: inside a
+-- transaction that sets audit_events.redact, the values in changes may be
+-- cleared and redacted_at set; nothing else can change and nothing can be deleted.
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS redacted_at TIMESTAMP;
+
+CREATE OR REPLACE FUNCTION audit_events_redact_only() RETURNS trigger AS $$
+BEGIN
+    IF current_setting('audit_events.redact', true) = 'on'
+        AND NEW.id = OLD.id
+        AND NEW.actor = OLD.actor
+        AND NEW.impersonator IS NOT DISTINCT FROM OLD.impersonator
+        AND NEW.action = OLD.action
+        AND NEW.target_id = OLD.target_id
+        AND NEW.request_id IS NOT DISTINCT FROM OLD.request_id
+        AND NEW.ip_address IS NOT DISTINCT FROM OLD.ip_address
+        AND NEW.created_at = OLD.created_at
+        AND OLD.redacted_at IS NULL AND NEW.redacted_at IS NOT NULL
+        AND NOT EXISTS (SELECT 1 FROM jsonb_each(NEW.changes) WHERE value <> '{}'::jsonb)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+    BEFORE DELETE OR TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE PROCEDURE audit_events_append_only();
+
+DROP TRIGGER IF EXISTS audit_events_redact_only ON audit_events;
+CREATE TRIGGER audit_events_redact_only
+    BEFORE UPDATE ON audit_events
+    FOR EACH ROW EXECUTE PROCEDURE audit_events_redact_only();